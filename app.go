@@ -76,6 +76,10 @@ type App struct {
 	server *fasthttp.Server
 	// App config
 	config Config
+	// Functions called after the server is shut down
+	shutdownHooks []func()
+	// Mutex for the shutdown hooks
+	hooksMutex sync.Mutex
 }
 
 // Config is a struct holding the server settings.
@@ -112,6 +116,7 @@ type Config struct {
 
 	// Enable or disable ETag header generation, since both weak and strong etags are generated
 	// using the same hashing method (CRC-32). Weak ETags are the default when enabled.
+	// The etag middleware supports strong ETags, other hash functions and per route configuration.
 	// Default: false
 	ETag bool `json:"etag"`
 
@@ -119,6 +124,16 @@ type Config struct {
 	// Default: 4 * 1024 * 1024
 	BodyLimit int `json:"body_limit"`
 
+	// When set to true, handlers are called as soon as the request headers
+	// are read and the body can be consumed with c.BodyStream() while it is
+	// being received. Bodies are still limited to BodyLimit when they are
+	// read, use the limit argument of c.BodyStream() to change it per route.
+	// A body that exceeds the limit makes c.Body() return nil, check
+	// c.BodyError() or use c.BodyParser() which return ErrRequestEntityTooLarge.
+	// Multipart forms are not parsed up front, see c.MultipartReader().
+	// Default: false
+	StreamRequestBody bool `json:"stream_request_body"`
+
 	// Maximum number of concurrent connections.
 	// Default: 256 * 1024
 	Concurrency int `json:"concurrency"`
@@ -176,6 +191,12 @@ type Config struct {
 	// ErrorHandler is executed when an error is returned from fiber.Handler.
 	ErrorHandler ErrorHandler `json:"-"`
 
+	// CookieSecrets are the keys used by c.SignedCookie and c.SignedCookies.
+	// New cookies are signed with the first secret, all secrets are tried when
+	// verifying so that old secrets can be rotated out.
+	// Default: nil
+	CookieSecrets []string `json:"-"`
+
 	// When set to true, disables keep-alive connections.
 	// The server will close incoming connections after sending the first response to client.
 	// Default: false
@@ -499,13 +520,32 @@ func (app *App) Stack() [][]*Route {
 // Make sure the program doesn't exit and waits instead for Shutdown to return.
 //
 // Shutdown does not close keepalive connections so its recommended to set ReadTimeout to something else than 0.
+//
+// The functions registered with OnShutdown are called after the server is shut down.
 func (app *App) Shutdown() error {
 	app.mutex.Lock()
 	defer app.mutex.Unlock()
 	if app.server == nil {
 		return fmt.Errorf("shutdown: server is not running")
 	}
-	return app.server.Shutdown()
+	err := app.server.Shutdown()
+
+	app.hooksMutex.Lock()
+	hooks := app.shutdownHooks
+	app.hooksMutex.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	return err
+}
+
+// OnShutdown registers a function that is called by Shutdown once all
+// connections are closed, e.g. to flush buffered log writers.
+// It is safe to call OnShutdown from a handler.
+func (app *App) OnShutdown(hook func()) {
+	app.hooksMutex.Lock()
+	app.shutdownHooks = append(app.shutdownHooks, hook)
+	app.hooksMutex.Unlock()
 }
 
 // Test is used for internal debugging by passing a *http.Request.
@@ -619,6 +659,8 @@ func (app *App) init() *App {
 	app.server.DisableHeaderNamesNormalizing = app.config.DisableHeaderNormalizing
 	app.server.DisableKeepalive = app.config.DisableKeepalive
 	app.server.MaxRequestBodySize = app.config.BodyLimit
+	app.server.StreamRequestBody = app.config.StreamRequestBody
+	app.server.DisablePreParseMultipartForm = app.config.StreamRequestBody
 	app.server.NoDefaultServerHeader = app.config.ServerHeader == ""
 	app.server.ReadTimeout = app.config.ReadTimeout
 	app.server.WriteTimeout = app.config.WriteTimeout
@@ -456,6 +456,17 @@ func Test_App_Shutdown(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("hooks", func(t *testing.T) {
+		app := New(Config{
+			DisableStartupMessage: true,
+		})
+		var calls []int
+		app.OnShutdown(func() { calls = append(calls, 1) })
+		app.OnShutdown(func() { calls = append(calls, 2) })
+		utils.AssertEqual(t, nil, app.Shutdown())
+		utils.AssertEqual(t, []int{1, 2}, calls)
+	})
 }
 
 // go test -run Test_App_Static_Index_Default
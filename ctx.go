@@ -5,13 +5,17 @@
 package fiber
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -45,6 +49,10 @@ type Ctx struct {
 	values       [maxParams]string    // Route parameter values
 	fasthttp     *fasthttp.RequestCtx // Reference to *fasthttp.RequestCtx
 	matched      bool                 // Non use route matched
+	userContext  context.Context      // Context returned by UserContext
+	cancel       context.CancelFunc   // Cancels the default UserContext
+	bodyErr      error                // Error of reading a streamed request body
+	bodyStream   *bodyStream          // Response body stream set by the Ctx
 }
 
 // Range data for c.Range
@@ -101,7 +109,16 @@ func (app *App) AcquireCtx(fctx *fasthttp.RequestCtx) *Ctx {
 func (app *App) ReleaseCtx(c *Ctx) {
 	// Reset values
 	c.route = nil
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	} else if c.fasthttp.Hijacked() {
+		cancelUserContext(c.fasthttp)
+	}
 	c.fasthttp = nil
+	c.userContext = nil
+	c.bodyErr = nil
+	c.bodyStream = nil
 	app.pool.Put(c)
 }
 
@@ -208,12 +225,76 @@ func (c *Ctx) BaseURL() string {
 }
 
 // Body contains the raw body submitted in a POST request.
+// With Config.StreamRequestBody enabled the body is read from the connection
+// on the first call, a body that can't be read, e.g. because it is larger than
+// Config.BodyLimit, is discarded and nil is returned. Use BodyError to tell
+// it apart from an empty body, BodyParser, FormFile and MultipartForm return the error.
 // Returned value is only valid within the handler. Do not store any references.
 // Make copies or use the Immutable setting instead.
 func (c *Ctx) Body() []byte {
+	if err := c.readBody(); err != nil {
+		return nil
+	}
 	return c.fasthttp.Request.Body()
 }
 
+// BodyError returns the error of reading a streamed request body, it is
+// ErrRequestEntityTooLarge for a body larger than Config.BodyLimit.
+// The body is read if it hasn't been yet, without StreamRequestBody it is nil.
+func (c *Ctx) BodyError() error {
+	return c.readBody()
+}
+
+// BodyStream returns a reader for the raw request body.
+// With Config.StreamRequestBody enabled the body is read from the connection
+// as the reader is consumed, otherwise it reads the buffered body.
+// The body size is limited to Config.BodyLimit bytes, an optional limit
+// overrides it for the route. Reading past the limit returns
+// ErrRequestEntityTooLarge.
+func (c *Ctx) BodyStream(limit ...int) io.Reader {
+	max := c.app.config.BodyLimit
+	if len(limit) > 0 && limit[0] > 0 {
+		max = limit[0]
+	}
+	if stream := c.fasthttp.RequestBodyStream(); stream != nil {
+		lr := newLimitedReader(stream, max, c.fasthttp.Request.Header.ContentLength())
+		// The rest of the body is not read, so the connection can't be reused
+		lr.onExceed = c.fasthttp.SetConnectionClose
+		return lr
+	}
+	if c.bodyErr != nil {
+		return errReader{c.bodyErr}
+	}
+	// The buffered body was limited when it was received
+	body := c.fasthttp.Request.Body()
+	if len(limit) > 0 && limit[0] > 0 {
+		return newLimitedReader(bytes.NewReader(body), max, len(body))
+	}
+	return bytes.NewReader(body)
+}
+
+// readBody reads a streamed request body into memory, limited to
+// Config.BodyLimit. The connection is closed when the body is too large,
+// the rest of it is not read.
+func (c *Ctx) readBody() error {
+	if c.bodyErr != nil {
+		return c.bodyErr
+	}
+	stream := c.fasthttp.RequestBodyStream()
+	if stream == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(newLimitedReader(stream, c.app.config.BodyLimit, c.fasthttp.Request.Header.ContentLength()))
+	if err != nil {
+		c.bodyErr = err
+		c.fasthttp.Request.SetBody(nil)
+		c.fasthttp.SetConnectionClose()
+		return err
+	}
+	c.fasthttp.Request.SetBody(body)
+	return nil
+}
+
 // decoderPool helps to improve BodyParser's and QueryParser's performance
 var decoderPool = &sync.Pool{New: func() interface{} {
 	var decoder = schema.NewDecoder()
@@ -225,6 +306,11 @@ var decoderPool = &sync.Pool{New: func() interface{} {
 // It supports decoding the following content types based on the Content-Type header:
 // application/json, application/xml, application/x-www-form-urlencoded, multipart/form-data
 func (c *Ctx) BodyParser(out interface{}) error {
+	// Read a streamed body within the body limit
+	if err := c.readBody(); err != nil {
+		return err
+	}
+
 	// Get decoder from pool
 	schemaDecoder := decoderPool.Get().(*schema.Decoder)
 	defer decoderPool.Put(schemaDecoder)
@@ -258,6 +344,65 @@ func (c *Ctx) BodyParser(out interface{}) error {
 	return fmt.Errorf("bodyparser: cannot parse content-type: %v", ctype)
 }
 
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since, If-None-Match
+// and If-Modified-Since request headers against the current ETag and
+// modification time of the resource, in the order given by RFC 7232 section 6.
+// Pass an empty etag and a zero time when the resource does not exist.
+// The validators are set as response headers. When a precondition fails the
+// response is set to 304 Not Modified for GET and HEAD requests or to
+// 412 Precondition Failed otherwise, and false is returned so the handler
+// can return without writing a body.
+func (c *Ctx) CheckPreconditions(etag string, lastModified time.Time) bool {
+	exists := etag != "" || !lastModified.IsZero()
+	// HTTP dates have a precision of one second
+	lastModified = lastModified.Truncate(time.Second)
+	if etag != "" {
+		c.Set(HeaderETag, etag)
+	}
+	if !lastModified.IsZero() {
+		c.Set(HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// 1. If-Match, else 2. If-Unmodified-Since
+	if ifMatch := c.Get(HeaderIfMatch); ifMatch != "" {
+		if !etagListMatch(ifMatch, etag, exists, false) {
+			return c.preconditionFailed()
+		}
+	} else if ifUnmodifiedSince := c.Get(HeaderIfUnmodifiedSince); ifUnmodifiedSince != "" && exists {
+		if t, err := http.ParseTime(ifUnmodifiedSince); err == nil && lastModified.After(t) {
+			return c.preconditionFailed()
+		}
+	}
+
+	isGetOrHead := c.methodINT == methodInt(MethodGet) || c.methodINT == methodInt(MethodHead)
+
+	// 3. If-None-Match, else 4. If-Modified-Since
+	if ifNoneMatch := c.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		if etagListMatch(ifNoneMatch, etag, exists, true) {
+			if isGetOrHead {
+				return c.notModified()
+			}
+			return c.preconditionFailed()
+		}
+	} else if ifModifiedSince := c.Get(HeaderIfModifiedSince); ifModifiedSince != "" && isGetOrHead && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ifModifiedSince); err == nil && !lastModified.After(t) {
+			return c.notModified()
+		}
+	}
+	return true
+}
+
+func (c *Ctx) notModified() bool {
+	c.Status(StatusNotModified)
+	c.fasthttp.ResetBody()
+	return false
+}
+
+func (c *Ctx) preconditionFailed() bool {
+	_ = c.SendStatus(StatusPreconditionFailed)
+	return false
+}
+
 // ClearCookie expires a specific cookie by key on the client side.
 // If no key is provided it expires all cookies that came with the request.
 func (c *Ctx) ClearCookie(key ...string) {
@@ -278,6 +423,23 @@ func (c *Ctx) Context() *fasthttp.RequestCtx {
 	return c.fasthttp
 }
 
+// UserContext returns the context.Context of the request, middleware can
+// wrap it with SetUserContext to add a deadline or values.
+// Defaults to a context that is cancelled when the client disconnects,
+// the server shuts down or the response, including a streamed body, has
+// been written.
+func (c *Ctx) UserContext() context.Context {
+	if c.userContext == nil {
+		c.userContext, c.cancel = newUserContext(c.fasthttp)
+	}
+	return c.userContext
+}
+
+// SetUserContext sets the context.Context returned by UserContext.
+func (c *Ctx) SetUserContext(ctx context.Context) {
+	c.userContext = ctx
+}
+
 // Cookie sets a cookie by passing a cookie struct.
 func (c *Ctx) Cookie(cookie *Cookie) {
 	fcookie := fasthttp.AcquireCookie()
@@ -311,6 +473,34 @@ func (c *Ctx) Cookies(key string, defaultValue ...string) string {
 	return defaultString(getString(c.fasthttp.Request.Header.Cookie(key)), defaultValue)
 }
 
+// ErrNoCookieSecrets is returned by c.SignedCookie when the app has no CookieSecrets
+var ErrNoCookieSecrets = errors.New("signedcookie: no CookieSecrets configured")
+
+// SignedCookie sets a cookie whose value is signed with HMAC-SHA256 using
+// the first of the app's CookieSecrets, so that it can be read back with
+// c.SignedCookies. It returns ErrNoCookieSecrets when no CookieSecrets are
+// configured.
+func (c *Ctx) SignedCookie(cookie *Cookie) error {
+	if len(c.app.config.CookieSecrets) == 0 {
+		return ErrNoCookieSecrets
+	}
+	signed := *cookie
+	signed.Value = signCookie(cookie.Name, cookie.Value, c.app.config.CookieSecrets[0])
+	c.Cookie(&signed)
+	return nil
+}
+
+// SignedCookies is used for getting a signed cookie value by key.
+// The signature is verified against all of the app's CookieSecrets.
+// Defaults to the empty string "" if the cookie doesn't exist or has an invalid signature.
+// If a default value is given, it will return that value instead.
+func (c *Ctx) SignedCookies(key string, defaultValue ...string) string {
+	if value, ok := unsignCookie(key, c.Cookies(key), c.app.config.CookieSecrets); ok {
+		return value
+	}
+	return defaultString("", defaultValue)
+}
+
 // Download transfers the file from path as an attachment.
 // Typically, browsers will prompt the user for download.
 // By default, the Content-Disposition header filename= parameter is the filepath (this typically appears in the browser dialog).
@@ -380,15 +570,22 @@ func (c *Ctx) Format(body interface{}) error {
 
 // FormFile returns the first file by key from a MultipartForm.
 func (c *Ctx) FormFile(key string) (*multipart.FileHeader, error) {
+	if err := c.readBody(); err != nil {
+		return nil, err
+	}
 	return c.fasthttp.FormFile(key)
 }
 
 // FormValue returns the first value by key from a MultipartForm.
-// Defaults to the empty string "" if the form value doesn't exist.
+// Defaults to the empty string "" if the form value doesn't exist,
+// or if a streamed body can't be read, see BodyError.
 // If a default value is given, it will return that value if the form value does not exist.
 // Returned value is only valid within the handler. Do not store any references.
 // Make copies or use the Immutable setting instead.
 func (c *Ctx) FormValue(key string, defaultValue ...string) string {
+	if err := c.readBody(); err != nil {
+		return defaultString("", defaultValue)
+	}
 	return defaultString(getString(c.fasthttp.FormValue(key)), defaultValue)
 }
 
@@ -397,6 +594,8 @@ func (c *Ctx) FormValue(key string, defaultValue ...string) string {
 // and the full response should be sent.
 // When a client sends the Cache-Control: no-cache request header to indicate an end-to-end
 // reload request, this module will return false to make handling these requests transparent.
+// A request with only If-Modified-Since is fresh if the Last-Modified response header
+// is not later, without Last-Modified it is stale.
 // https://github.com/jshttp/fresh/blob/10e0471669dbbfbfd8de65bc6efac2ddd0bfa057/index.js#L33
 func (c *Ctx) Fresh() bool {
 	// fields
@@ -441,6 +640,23 @@ func (c *Ctx) Fresh() bool {
 			}
 		}
 	}
+
+	// if-modified-since, only used without if-none-match
+	if noneMatch == "" {
+		var lastModified = getString(c.fasthttp.Response.Header.Peek(HeaderLastModified))
+		if lastModified == "" {
+			return false
+		}
+		lastModifiedTime, err := http.ParseTime(lastModified)
+		if err != nil {
+			return false
+		}
+		modifiedSinceTime, err := http.ParseTime(modifiedSince)
+		if err != nil {
+			return false
+		}
+		return !lastModifiedTime.After(modifiedSinceTime)
+	}
 	return true
 }
 
@@ -589,9 +805,23 @@ func (c *Ctx) Method(override ...string) string {
 // MultipartForm parse form entries from binary.
 // This returns a map[string][]string, so given a key the value will be a string slice.
 func (c *Ctx) MultipartForm() (*multipart.Form, error) {
+	if err := c.readBody(); err != nil {
+		return nil, err
+	}
 	return c.fasthttp.MultipartForm()
 }
 
+// MultipartReader returns a reader to iterate over the parts of a
+// multipart/form-data body one at a time, so uploads can be processed
+// without buffering them. The optional limit is passed to BodyStream.
+func (c *Ctx) MultipartReader(limit ...int) (*multipart.Reader, error) {
+	boundary := c.fasthttp.Request.Header.MultipartFormBoundary()
+	if len(boundary) == 0 {
+		return nil, fasthttp.ErrNoMultipartForm
+	}
+	return multipart.NewReader(c.BodyStream(limit...), string(boundary)), nil
+}
+
 // Next executes the next method in the stack that matches the current route.
 func (c *Ctx) Next() (err error) {
 	// Increment handler index
@@ -833,6 +1063,23 @@ func (c *Ctx) Route() *Route {
 	return c.route
 }
 
+// NextRoutes returns the routes after the current one that match the request,
+// in the order Next executes them. The routes are not executed.
+func (c *Ctx) NextRoutes() []*Route {
+	tree, ok := c.app.treeStack[c.methodINT][c.treePath]
+	if !ok {
+		tree = c.app.treeStack[c.methodINT][""]
+	}
+	var params [maxParams]string
+	var routes []*Route
+	for i := c.indexRoute + 1; i < len(tree); i++ {
+		if tree[i].match(c.path, c.pathOriginal, &params) {
+			routes = append(routes, tree[i])
+		}
+	}
+	return routes
+}
+
 // SaveFile saves any multipart file to disk.
 func (c *Ctx) SaveFile(fileheader *multipart.FileHeader, path string) error {
 	return fasthttp.SaveMultipartFile(fileheader, path)
@@ -859,6 +1106,10 @@ var sendFileHandler fasthttp.RequestHandler
 // The file is not compressed by default, enable this by passing a 'true' argument
 // Sets the Content-Type response HTTP header field based on the filenames extension.
 func (c *Ctx) SendFile(file string, compress ...bool) error {
+	// fasthttp only serves single ranges and ignores If-Range
+	rangeHeader := c.Get(HeaderRange)
+	sendRanges := strings.IndexByte(rangeHeader, ',') != -1 || (rangeHeader != "" && c.Get(HeaderIfRange) != "")
+
 	// https://github.com/valyala/fasthttp/blob/master/fs.go#L81
 	sendFileOnce.Do(func() {
 		sendFileFS = &fasthttp.FS{
@@ -894,6 +1145,12 @@ func (c *Ctx) SendFile(file string, compress ...bool) error {
 			file += "/"
 		}
 	}
+	// Serve multiple or conditional ranges ourselves
+	if sendRanges {
+		if served, err := c.sendFileRanges(file); served {
+			return err
+		}
+	}
 	// Set new URI for fileHandler
 	c.fasthttp.Request.SetRequestURI(file)
 	// Save status code
@@ -913,13 +1170,123 @@ func (c *Ctx) SendFile(file string, compress ...bool) error {
 	return nil
 }
 
+// SendRanges sends the content of reader as a response to the Range request header.
+// A single range is sent with 206 Partial Content, multiple ranges as a
+// multipart/byteranges body, overlapping ranges are coalesced first.
+// If-Range is validated against the ETag and Last-Modified response headers,
+// so set them before calling SendRanges. Unsatisfiable ranges are answered with
+// 416 and Content-Range: bytes */size, without a usable Range header the whole
+// content is sent, as it is for more than 100 ranges. Like SendStream, the reader is consumed after the handler
+// has returned and is closed if it implements io.Closer.
+func (c *Ctx) SendRanges(reader io.ReadSeeker, size int, contentType string) error {
+	c.setCanonical(HeaderAcceptRanges, "bytes")
+	if contentType != "" {
+		c.fasthttp.Response.Header.SetContentType(contentType)
+	}
+
+	// Send the whole content for plain or outdated conditional requests
+	if c.Get(HeaderRange) == "" || !c.ifRange() {
+		c.setBodyStream(newRangeReader(reader, reader, 0, size), size)
+		return nil
+	}
+	rangeData, err := c.Range(size)
+	if err == ErrRangeUnsatisfiable {
+		c.setCanonical(HeaderContentRange, "bytes */"+strconv.Itoa(size))
+		return c.SendStatus(StatusRequestedRangeNotSatisfiable)
+	}
+	// Malformed ranges and unknown units are ignored, like net/http also too
+	// many ranges or ranges adding up to more than the content, which is
+	// probably an attack
+	if err != nil || rangeData.Type != "bytes" || len(rangeData.Ranges) > maxRanges || sumRanges(rangeData.Ranges) > size {
+		c.setBodyStream(newRangeReader(reader, reader, 0, size), size)
+		return nil
+	}
+
+	ranges := coalesceRanges(rangeData.Ranges)
+	c.Status(StatusPartialContent)
+
+	// Single part
+	if len(ranges) == 1 {
+		start, end := ranges[0].Start, ranges[0].End
+		c.setCanonical(HeaderContentRange, "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(end)+"/"+strconv.Itoa(size))
+		c.setBodyStream(newRangeReader(reader, reader, start, end-start+1), end-start+1)
+		return nil
+	}
+
+	// Multipart, every part carries its own headers
+	boundary := utils.UUID()
+	parts := make([]io.Reader, 0, len(ranges)*2+1)
+	length := 0
+	for i, r := range ranges {
+		header := "--" + boundary + "\r\n"
+		if i > 0 {
+			header = "\r\n" + header
+		}
+		if contentType != "" {
+			header += HeaderContentType + ": " + contentType + "\r\n"
+		}
+		header += HeaderContentRange + ": bytes " + strconv.Itoa(r.Start) + "-" + strconv.Itoa(r.End) + "/" + strconv.Itoa(size) + "\r\n\r\n"
+		parts = append(parts, strings.NewReader(header), newRangeReader(reader, nil, r.Start, r.End-r.Start+1))
+		length += len(header) + r.End - r.Start + 1
+	}
+	trailer := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(trailer))
+	length += len(trailer)
+
+	c.fasthttp.Response.Header.SetContentType("multipart/byteranges; boundary=" + boundary)
+	c.setBodyStream(&readCloser{Reader: io.MultiReader(parts...), closer: reader}, length)
+	return nil
+}
+
+// ifRange reports whether the If-Range precondition allows a partial response
+func (c *Ctx) ifRange() bool {
+	ifRange := c.Get(HeaderIfRange)
+	if ifRange == "" {
+		return true
+	}
+	// Entity tags must match strongly
+	if ifRange[0] == '"' || strings.HasPrefix(ifRange, "W/") {
+		etag := getString(c.fasthttp.Response.Header.Peek(HeaderETag))
+		return !strings.HasPrefix(ifRange, "W/") && etag == ifRange
+	}
+	lastModified := getString(c.fasthttp.Response.Header.Peek(HeaderLastModified))
+	if lastModified == "" {
+		return false
+	}
+	ifRangeTime, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	lastModifiedTime, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return lastModifiedTime.Equal(ifRangeTime)
+}
+
+// sendFileRanges serves a regular file with SendRanges, directories are left to fasthttp
+func (c *Ctx) sendFileRanges(file string) (served bool, err error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return true, fmt.Errorf("sendfile: file %s not found", file)
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		_ = f.Close()
+		return false, nil
+	}
+	c.setCanonical(HeaderLastModified, fi.ModTime().UTC().Format(http.TimeFormat))
+	return true, c.SendRanges(f, int(fi.Size()), utils.GetMIME(filepath.Ext(file)))
+}
+
 // SendStatus sets the HTTP status code and if the response body is empty,
 // it sets the correct status message in the body.
 func (c *Ctx) SendStatus(status int) error {
 	c.Status(status)
 
-	// Only set status body when there is no response body
-	if len(c.fasthttp.Response.Body()) == 0 {
+	// Only set status body when there is no response body,
+	// reading a body stream here would buffer it entirely
+	if !c.fasthttp.Response.IsBodyStream() && len(c.fasthttp.Response.Body()) == 0 {
 		return c.SendString(utils.StatusMessage(status))
 	}
 
@@ -937,15 +1304,104 @@ func (c *Ctx) SendString(body string) error {
 // SendStream sets response body stream and optional body size.
 func (c *Ctx) SendStream(stream io.Reader, size ...int) error {
 	if len(size) > 0 && size[0] >= 0 {
-		c.fasthttp.Response.SetBodyStream(stream, size[0])
+		c.setBodyStream(stream, size[0])
 	} else {
-		c.fasthttp.Response.SetBodyStream(stream, -1)
+		c.setBodyStream(stream, -1)
 		c.setCanonical(HeaderContentLength, strconv.Itoa(len(c.fasthttp.Response.Body())))
 	}
 
 	return nil
 }
 
+// SendStreamWriter sets a function that writes the response body.
+// The function is called after the handler has returned, so it must not
+// access the Ctx, and the body is sent with chunked transfer encoding.
+// Call w.Flush() to send the buffered data to the client right away,
+// an error from Flush means the client has disconnected.
+func (c *Ctx) SendStreamWriter(streamWriter func(w *bufio.Writer)) error {
+	c.sendStreamWriter(&streamSent{}, streamWriter)
+
+	return nil
+}
+
+// sendStreamWriter sets the stream writer, sent calls the OnStreamSent functions
+func (c *Ctx) sendStreamWriter(sent *streamSent, streamWriter func(w *bufio.Writer)) {
+	sent.body = &countingReader{Reader: fasthttp.NewStreamReader(streamWriter)}
+	c.setBodyStream(sent.body, -1)
+	c.fasthttp.SetUserValue(streamSentKey, sent)
+}
+
+// setBodyStream sets a response body stream that TakeBodyStream can take back
+func (c *Ctx) setBodyStream(stream io.Reader, size int) {
+	c.bodyStream = &bodyStream{Reader: stream}
+	c.fasthttp.Response.SetBodyStream(c.bodyStream, size)
+}
+
+// OnStreamSent registers a function that is called with the number of body bytes
+// sent once the stream set by SendStreamWriter or SSE has been written to the client.
+// err is the error returned by the SSE handler if it ended the stream, the route
+// handler has returned by then so the error can't reach the ErrorHandler.
+// It returns false if the response body isn't such a stream, fn is not called then.
+func (c *Ctx) OnStreamSent(fn func(n int64, err error)) bool {
+	if !c.fasthttp.Response.IsBodyStream() {
+		return false
+	}
+	s, ok := c.fasthttp.UserValue(streamSentKey).(*streamSent)
+	if !ok {
+		return false
+	}
+	s.fns = append(s.fns, fn)
+	return true
+}
+
+// SSE opens a Server-Sent Events stream and calls the handler with a writer
+// for pushing events to the client. The handler runs after the route handler
+// has returned, so it must not access the Ctx; use SSEWriter.LastEventID to
+// resume a stream. A comment is sent every heartbeat interval to keep the
+// connection alive, the interval defaults to DefaultSSEHeartbeat and a
+// negative value disables it. SSEWriter.Done is closed when the client
+// disconnects or UserContext is done. The stream ends when the handler
+// returns, its error is passed to the OnStreamSent functions.
+func (c *Ctx) SSE(handler func(w *SSEWriter) error, heartbeat ...time.Duration) error {
+	interval := DefaultSSEHeartbeat
+	if len(heartbeat) > 0 && heartbeat[0] != 0 {
+		interval = heartbeat[0]
+	}
+	// Copy the header, the stream outlives the request context
+	lastEventID := utils.ImmutableString(c.Get(HeaderLastEventID))
+	// Cancelled when the client disconnects, also while the stream is idle
+	ctx := c.UserContext()
+
+	c.fasthttp.Response.Header.SetContentType(MIMETextEventStream)
+	c.setCanonical(HeaderCacheControl, "no-cache")
+	c.setCanonical(HeaderConnection, "keep-alive")
+	c.setCanonical(HeaderXAccelBuffering, "no")
+
+	sent := &streamSent{}
+	c.sendStreamWriter(sent, func(w *bufio.Writer) {
+		sw := &SSEWriter{
+			w:           w,
+			lastEventID: lastEventID,
+			done:        make(chan struct{}),
+		}
+		// Send headers to the client right away
+		if err := sw.Comment("ok"); err != nil {
+			return
+		}
+		if interval > 0 {
+			go sw.heartbeat(interval)
+		}
+		go sw.watch(ctx)
+		sent.setErr(handler(sw))
+
+		sw.mutex.Lock()
+		sw.close()
+		sw.mutex.Unlock()
+	})
+
+	return nil
+}
+
 // Set sets the response's HTTP header field to the specified key, value.
 func (c *Ctx) Set(key string, val string) {
 	c.fasthttp.Response.Header.Set(key, removeNewLines(val))
@@ -998,6 +1454,22 @@ func (c *Ctx) String() string {
 	)
 }
 
+// TakeBodyStream removes the response body stream set by SendStream, SendRanges,
+// SendStreamWriter or SSE and returns it without closing it, so that it can be
+// wrapped and set again. The caller must close the returned stream.
+// It returns nil if the response body isn't such a stream.
+func (c *Ctx) TakeBodyStream() io.ReadCloser {
+	s := c.bodyStream
+	if s == nil || s.closed || !c.fasthttp.Response.IsBodyStream() {
+		return nil
+	}
+	// Resetting the body closes the stream, unless it is taken
+	s.taken = true
+	c.fasthttp.Response.ResetBody()
+	c.bodyStream = nil
+	return &readCloser{Reader: s.Reader, closer: s.Reader}
+}
+
 // Type sets the Content-Type HTTP header to the MIME type specified by the file extension.
 func (c *Ctx) Type(extension string, charset ...string) *Ctx {
 	if len(charset) > 0 {
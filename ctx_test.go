@@ -10,11 +10,14 @@ package fiber
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
@@ -285,6 +288,160 @@ func Test_Ctx_Body(t *testing.T) {
 	utils.AssertEqual(t, []byte("john=doe"), c.Body())
 }
 
+// go test -run Test_Ctx_BodyStream
+func Test_Ctx_BodyStream(t *testing.T) {
+	t.Parallel()
+	app := New(Config{BodyLimit: 16, StreamRequestBody: true})
+	app.Post("/", func(c *Ctx) error {
+		n, err := io.Copy(ioutil.Discard, c.BodyStream())
+		if err != nil {
+			return err
+		}
+		return c.SendString(strconv.FormatInt(n, 10))
+	})
+	app.Post("/limit", func(c *Ctx) error {
+		n, err := io.Copy(ioutil.Discard, c.BodyStream(1024))
+		if err != nil {
+			return err
+		}
+		return c.SendString(strconv.FormatInt(n, 10))
+	})
+
+	body := strings.Repeat("fiber", 100)
+
+	// The body is limited to BodyLimit
+	resp, err := app.Test(httptest.NewRequest(MethodPost, "/", strings.NewReader(body)))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/", strings.NewReader("fiber")))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "5", string(b))
+
+	// The limit argument overrides BodyLimit
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/limit", strings.NewReader(body)))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "500", string(b))
+
+	// Buffered bodies are read from memory
+	app = New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	c.Request().SetBody([]byte("john=doe"))
+	b, err = ioutil.ReadAll(c.BodyStream())
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("john=doe"), b)
+	_, err = ioutil.ReadAll(c.BodyStream(4))
+	utils.AssertEqual(t, ErrRequestEntityTooLarge, err)
+}
+
+// go test -run Test_Ctx_Body_Stream_Limit
+func Test_Ctx_Body_Stream_Limit(t *testing.T) {
+	t.Parallel()
+	app := New(Config{BodyLimit: 16, StreamRequestBody: true})
+	app.Post("/body", func(c *Ctx) error {
+		body := c.Body()
+		if err := c.BodyError(); err != nil {
+			return err
+		}
+		return c.Send(body)
+	})
+	app.Post("/getter", func(c *Ctx) error {
+		// Reading the body doesn't change the response
+		utils.AssertEqual(t, 0, len(c.Body()))
+		utils.AssertEqual(t, "", c.FormValue("name"))
+		utils.AssertEqual(t, StatusOK, c.Response().StatusCode())
+		return c.SendStatus(StatusAccepted)
+	})
+	app.Post("/parser", func(c *Ctx) error {
+		var data map[string]string
+		if err := c.BodyParser(&data); err != nil {
+			return err
+		}
+		return c.SendString(data["name"])
+	})
+
+	req := httptest.NewRequest(MethodPost, "/body", strings.NewReader(strings.Repeat("x", 100000)))
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusRequestEntityTooLarge, resp.StatusCode)
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "Request Entity Too Large", string(b))
+
+	req = httptest.NewRequest(MethodPost, "/getter", strings.NewReader(strings.Repeat("x", 100000)))
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusAccepted, resp.StatusCode)
+
+	req = httptest.NewRequest(MethodPost, "/body", strings.NewReader("fiber"))
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "fiber", string(b))
+
+	req = httptest.NewRequest(MethodPost, "/parser", strings.NewReader(`{"name":"`+strings.Repeat("x", 100)+`"}`))
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusRequestEntityTooLarge, resp.StatusCode)
+
+	req = httptest.NewRequest(MethodPost, "/parser", strings.NewReader(`{"name":"john"}`))
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "john", string(b))
+}
+
+// go test -run Test_Ctx_MultipartReader
+func Test_Ctx_MultipartReader(t *testing.T) {
+	t.Parallel()
+	app := New(Config{BodyLimit: 16, StreamRequestBody: true})
+	app.Post("/", func(c *Ctx) error {
+		mr, err := c.MultipartReader(4096)
+		if err != nil {
+			return err
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(ioutil.Discard, part)
+		if err != nil {
+			return err
+		}
+		return c.SendString(part.FileName() + ":" + strconv.FormatInt(n, 10))
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	ioWriter, err := writer.CreateFormFile("file", "upload.bin")
+	utils.AssertEqual(t, nil, err)
+	_, err = ioWriter.Write(bytes.Repeat([]byte("x"), 1024))
+	utils.AssertEqual(t, nil, err)
+	writer.Close()
+
+	req := httptest.NewRequest(MethodPost, "/", body)
+	req.Header.Set(HeaderContentType, writer.FormDataContentType())
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "upload.bin:1024", string(b))
+}
+
 // go test -run Test_Ctx_BodyParser
 func Test_Ctx_BodyParser(t *testing.T) {
 	t.Parallel()
@@ -429,6 +586,143 @@ func Test_Ctx_Context(t *testing.T) {
 	utils.AssertEqual(t, "*fasthttp.RequestCtx", fmt.Sprintf("%T", c.Context()))
 }
 
+// go test -run Test_Ctx_UserContext
+func Test_Ctx_UserContext(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	ctx := c.UserContext()
+	utils.AssertEqual(t, nil, ctx.Err())
+	utils.AssertEqual(t, ctx, c.UserContext())
+
+	// Cancelled when the ctx is released
+	c2 := app.AcquireCtx(&fasthttp.RequestCtx{})
+	released := c2.UserContext()
+	app.ReleaseCtx(c2)
+	utils.AssertEqual(t, context.Canceled, released.Err())
+
+	type key struct{}
+	ctx = context.WithValue(c.UserContext(), key{}, "value")
+	c.SetUserContext(ctx)
+	utils.AssertEqual(t, ctx, c.UserContext())
+	utils.AssertEqual(t, "value", c.UserContext().Value(key{}))
+}
+
+// go test -run Test_Ctx_UserContext_Cancel
+func Test_Ctx_UserContext_Cancel(t *testing.T) {
+	t.Parallel()
+	app := New(Config{
+		DisableStartupMessage: true,
+	})
+	errs := make(chan error, 1)
+	app.Get("/", func(c *Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			errs <- c.UserContext().Err()
+		case <-time.After(2 * time.Second):
+			errs <- nil
+		}
+		return nil
+	})
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	go func() {
+		_ = app.Listener(ln)
+	}()
+
+	// Client disconnects
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	utils.AssertEqual(t, nil, err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(50 * time.Millisecond)
+	utils.AssertEqual(t, nil, conn.Close())
+	utils.AssertEqual(t, context.Canceled, <-errs)
+
+	// Server shuts down
+	conn, err = net.Dial("tcp4", ln.Addr().String())
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		_ = app.Shutdown()
+	}()
+	utils.AssertEqual(t, context.Canceled, <-errs)
+}
+
+// go test -run Test_Ctx_UserContext_Cancel_Watch
+func Test_Ctx_UserContext_Cancel_Watch(t *testing.T) {
+	t.Parallel()
+	app := New(Config{
+		DisableStartupMessage: true,
+	})
+	errs := make(chan error, 1)
+	app.Get("/", func(c *Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			errs <- c.UserContext().Err()
+		case <-time.After(2 * time.Second):
+			errs <- nil
+		}
+		return nil
+	})
+	streamed := make(chan error, 1)
+	app.Get("/stream", func(c *Ctx) error {
+		ctx := c.UserContext()
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			// Not cancelled until the body has been written, Flush
+			// returns once the server sends the response
+			_, _ = w.WriteString("body")
+			_ = w.Flush()
+			streamed <- ctx.Err()
+			go func() {
+				<-ctx.Done()
+				streamed <- ctx.Err()
+			}()
+		})
+	})
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	// Client disconnects after sending the start of the next request
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	utils.AssertEqual(t, nil, err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = conn.Write([]byte("GET"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(50 * time.Millisecond)
+	utils.AssertEqual(t, nil, conn.Close())
+	utils.AssertEqual(t, context.Canceled, <-errs)
+
+	// Streamed body
+	conn, err = net.Dial("tcp4", ln.Addr().String())
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /stream HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, <-streamed)
+	utils.AssertEqual(t, context.Canceled, <-streamed)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	utils.AssertEqual(t, nil, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "body", string(body))
+}
+
 // go test -run Test_Ctx_Cookie
 func Test_Ctx_Cookie(t *testing.T) {
 	t.Parallel()
@@ -478,6 +772,50 @@ func Test_Ctx_Cookies(t *testing.T) {
 	utils.AssertEqual(t, "default", c.Cookies("unknown", "default"))
 }
 
+// go test -run Test_Ctx_SignedCookie
+func Test_Ctx_SignedCookie(t *testing.T) {
+	t.Parallel()
+	app := New(Config{CookieSecrets: []string{"new-secret", "old-secret"}})
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	utils.AssertEqual(t, nil, c.SignedCookie(&Cookie{Name: "flags", Value: "beta"}))
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("flags")
+	utils.AssertEqual(t, true, c.Response().Header.Cookie(cookie))
+	signed := string(cookie.Value())
+	utils.AssertEqual(t, true, strings.HasPrefix(signed, "beta."))
+
+	c.Request().Header.SetCookie("flags", signed)
+	utils.AssertEqual(t, "beta", c.SignedCookies("flags"))
+
+	// Signed with a rotated secret
+	c.Request().Header.SetCookie("flags", signCookie("flags", "alpha", "old-secret"))
+	utils.AssertEqual(t, "alpha", c.SignedCookies("flags"))
+
+	// Tampered value, unknown secret, cookie name swapped, unsigned
+	c.Request().Header.SetCookie("flags", "admin"+signed[len("beta"):])
+	utils.AssertEqual(t, "", c.SignedCookies("flags"))
+	c.Request().Header.SetCookie("flags", signCookie("flags", "beta", "unknown"))
+	utils.AssertEqual(t, "default", c.SignedCookies("flags", "default"))
+	c.Request().Header.SetCookie("other", signed)
+	utils.AssertEqual(t, "", c.SignedCookies("other"))
+	c.Request().Header.SetCookie("flags", "beta")
+	utils.AssertEqual(t, "", c.SignedCookies("flags"))
+	utils.AssertEqual(t, "default", c.SignedCookies("unknown", "default"))
+}
+
+// go test -run Test_Ctx_SignedCookie_NoSecrets
+func Test_Ctx_SignedCookie_NoSecrets(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	utils.AssertEqual(t, ErrNoCookieSecrets, c.SignedCookie(&Cookie{Name: "flags", Value: "beta"}))
+	utils.AssertEqual(t, "", string(c.Response().Header.Peek(HeaderSetCookie)))
+}
+
 // go test -run Test_Ctx_Format
 func Test_Ctx_Format(t *testing.T) {
 	t.Parallel()
@@ -701,6 +1039,40 @@ func Test_Ctx_Fresh(t *testing.T) {
 	utils.AssertEqual(t, false, c.Fresh())
 }
 
+// go test -run Test_Ctx_Fresh_IfModifiedSince
+func Test_Ctx_Fresh_IfModifiedSince(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	// Stale without Last-Modified
+	c.Request().Header.Set(HeaderIfModifiedSince, "Wed, 21 Oct 2015 07:28:00 GMT")
+	utils.AssertEqual(t, false, c.Fresh())
+	utils.AssertEqual(t, true, c.Stale())
+
+	c.Response().Header.Set(HeaderLastModified, "Wed, 21 Oct 2015 07:28:00 GMT")
+	utils.AssertEqual(t, true, c.Fresh())
+
+	c.Response().Header.Set(HeaderLastModified, "Tue, 20 Oct 2015 07:28:00 GMT")
+	utils.AssertEqual(t, true, c.Fresh())
+
+	c.Response().Header.Set(HeaderLastModified, "Thu, 22 Oct 2015 07:28:00 GMT")
+	utils.AssertEqual(t, false, c.Fresh())
+
+	c.Response().Header.Set(HeaderLastModified, "invalid")
+	utils.AssertEqual(t, false, c.Fresh())
+
+	c.Response().Header.Set(HeaderLastModified, "Wed, 21 Oct 2015 07:28:00 GMT")
+	c.Request().Header.Set(HeaderIfModifiedSince, "invalid")
+	utils.AssertEqual(t, false, c.Fresh())
+
+	// no-cache requests are always stale
+	c.Request().Header.Set(HeaderIfModifiedSince, "Wed, 21 Oct 2015 07:28:00 GMT")
+	c.Request().Header.Set(HeaderCacheControl, "no-cache")
+	utils.AssertEqual(t, false, c.Fresh())
+}
+
 // go test -v -run=^$ -bench=Benchmark_Ctx_Fresh_WithNoCache -benchmem -count=4
 func Benchmark_Ctx_Fresh_WithNoCache(b *testing.B) {
 	app := New()
@@ -1213,6 +1585,90 @@ func Benchmark_Ctx_Subdomains(b *testing.B) {
 	utils.AssertEqual(b, []string{"john", "doe"}, res)
 }
 
+// go test -run Test_Ctx_CheckPreconditions
+func Test_Ctx_CheckPreconditions(t *testing.T) {
+	t.Parallel()
+	app := New()
+	modified := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	etag := `"abc"`
+
+	check := func(method string, headers map[string]string, etag string, modified time.Time) (bool, int) {
+		c := app.AcquireCtx(&fasthttp.RequestCtx{})
+		defer app.ReleaseCtx(c)
+		c.Request().Header.SetMethod(method)
+		c.method = method
+		c.methodINT = methodInt(method)
+		for k, v := range headers {
+			c.Request().Header.Set(k, v)
+		}
+		ok := c.CheckPreconditions(etag, modified)
+		return ok, c.Response().StatusCode()
+	}
+
+	// No conditional headers
+	ok, status := check(MethodGet, nil, etag, modified)
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, StatusOK, status)
+
+	// If-Match
+	ok, _ = check(MethodPut, map[string]string{HeaderIfMatch: `"xyz", "abc"`}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+	ok, status = check(MethodPut, map[string]string{HeaderIfMatch: `"xyz"`}, etag, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusPreconditionFailed, status)
+	ok, status = check(MethodPut, map[string]string{HeaderIfMatch: `W/"abc"`}, `W/"abc"`, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusPreconditionFailed, status)
+	ok, _ = check(MethodDelete, map[string]string{HeaderIfMatch: "*"}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+	ok, status = check(MethodDelete, map[string]string{HeaderIfMatch: "*"}, "", time.Time{})
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusPreconditionFailed, status)
+
+	// If-Unmodified-Since, ignored when If-Match is present
+	ok, status = check(MethodPatch, map[string]string{HeaderIfUnmodifiedSince: modified.Add(-time.Hour).Format(http.TimeFormat)}, etag, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusPreconditionFailed, status)
+	ok, _ = check(MethodPatch, map[string]string{HeaderIfUnmodifiedSince: modified.Format(http.TimeFormat)}, etag, modified.Add(500*time.Millisecond))
+	utils.AssertEqual(t, true, ok)
+	ok, _ = check(MethodPatch, map[string]string{
+		HeaderIfMatch:           etag,
+		HeaderIfUnmodifiedSince: modified.Add(-time.Hour).Format(http.TimeFormat),
+	}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+
+	// If-None-Match
+	ok, status = check(MethodGet, map[string]string{HeaderIfNoneMatch: `"xyz", W/"abc"`}, etag, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusNotModified, status)
+	ok, status = check(MethodPut, map[string]string{HeaderIfNoneMatch: "*"}, etag, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusPreconditionFailed, status)
+	ok, _ = check(MethodPut, map[string]string{HeaderIfNoneMatch: "*"}, "", time.Time{})
+	utils.AssertEqual(t, true, ok)
+
+	// If-Modified-Since, ignored when If-None-Match is present
+	ok, status = check(MethodHead, map[string]string{HeaderIfModifiedSince: modified.Format(http.TimeFormat)}, etag, modified)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, StatusNotModified, status)
+	ok, _ = check(MethodGet, map[string]string{HeaderIfModifiedSince: modified.Add(-time.Hour).Format(http.TimeFormat)}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+	ok, _ = check(MethodPost, map[string]string{HeaderIfModifiedSince: modified.Format(http.TimeFormat)}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+	ok, _ = check(MethodGet, map[string]string{
+		HeaderIfNoneMatch:     `"xyz"`,
+		HeaderIfModifiedSince: modified.Format(http.TimeFormat),
+	}, etag, modified)
+	utils.AssertEqual(t, true, ok)
+
+	// Validators are set on the response
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	utils.AssertEqual(t, true, c.CheckPreconditions(etag, modified))
+	utils.AssertEqual(t, etag, string(c.Response().Header.Peek(HeaderETag)))
+	utils.AssertEqual(t, "Thu, 01 Oct 2020 12:00:00 GMT", string(c.Response().Header.Peek(HeaderLastModified)))
+}
+
 // go test -run Test_Ctx_ClearCookie
 func Test_Ctx_ClearCookie(t *testing.T) {
 	t.Parallel()
@@ -1658,6 +2114,103 @@ func Benchmark_Ctx_Send(b *testing.B) {
 	utils.AssertEqual(b, "Hello, World!", string(c.Response().Body()))
 }
 
+// go test -run Test_Ctx_SendRanges
+func Test_Ctx_SendRanges(t *testing.T) {
+	t.Parallel()
+	app := New()
+	content := "0123456789abcdefghij"
+
+	send := func(rangeHeader, ifRange string) *Ctx {
+		c := app.AcquireCtx(&fasthttp.RequestCtx{})
+		if rangeHeader != "" {
+			c.Request().Header.Set(HeaderRange, rangeHeader)
+		}
+		if ifRange != "" {
+			c.Request().Header.Set(HeaderIfRange, ifRange)
+		}
+		c.Set(HeaderETag, `"v1"`)
+		utils.AssertEqual(t, nil, c.SendRanges(strings.NewReader(content), len(content), MIMETextPlain))
+		return c
+	}
+
+	// No range
+	c := send("", "")
+	utils.AssertEqual(t, StatusOK, c.Response().StatusCode())
+	utils.AssertEqual(t, "bytes", string(c.Response().Header.Peek(HeaderAcceptRanges)))
+	utils.AssertEqual(t, content, string(c.Response().Body()))
+	app.ReleaseCtx(c)
+
+	// Single range
+	c = send("bytes=2-5", "")
+	utils.AssertEqual(t, StatusPartialContent, c.Response().StatusCode())
+	utils.AssertEqual(t, "bytes 2-5/20", string(c.Response().Header.Peek(HeaderContentRange)))
+	utils.AssertEqual(t, "2345", string(c.Response().Body()))
+	app.ReleaseCtx(c)
+
+	// Overlapping ranges are coalesced
+	c = send("bytes=4-8,0-5,-2", "")
+	utils.AssertEqual(t, StatusPartialContent, c.Response().StatusCode())
+	body := string(c.Response().Body())
+	utils.AssertEqual(t, true, strings.HasPrefix(string(c.Response().Header.ContentType()), "multipart/byteranges; boundary="))
+	utils.AssertEqual(t, true, strings.Contains(body, "Content-Range: bytes 0-8/20\r\n\r\n012345678\r\n"))
+	utils.AssertEqual(t, true, strings.Contains(body, "Content-Range: bytes 18-19/20\r\n\r\nij\r\n"))
+	utils.AssertEqual(t, len(body), c.Response().Header.ContentLength())
+	app.ReleaseCtx(c)
+
+	// Ranges adding up to more than the content are ignored
+	c = send("bytes=0-15,5-19", "")
+	utils.AssertEqual(t, StatusOK, c.Response().StatusCode())
+	utils.AssertEqual(t, content, string(c.Response().Body()))
+	app.ReleaseCtx(c)
+
+	// Unsatisfiable
+	c = send("bytes=30-40", "")
+	utils.AssertEqual(t, StatusRequestedRangeNotSatisfiable, c.Response().StatusCode())
+	utils.AssertEqual(t, "bytes */20", string(c.Response().Header.Peek(HeaderContentRange)))
+	app.ReleaseCtx(c)
+
+	// If-Range
+	c = send("bytes=2-5", `"v1"`)
+	utils.AssertEqual(t, StatusPartialContent, c.Response().StatusCode())
+	app.ReleaseCtx(c)
+	c = send("bytes=2-5", `"v0"`)
+	utils.AssertEqual(t, StatusOK, c.Response().StatusCode())
+	utils.AssertEqual(t, content, string(c.Response().Body()))
+	app.ReleaseCtx(c)
+
+	// Too many ranges
+	large := strings.Repeat(content, 20)
+	ranges := make([]string, maxRanges+1)
+	for i := range ranges {
+		ranges[i] = strconv.Itoa(i*2) + "-" + strconv.Itoa(i*2)
+	}
+	c = app.AcquireCtx(&fasthttp.RequestCtx{})
+	c.Request().Header.Set(HeaderRange, "bytes="+strings.Join(ranges[:maxRanges], ","))
+	utils.AssertEqual(t, nil, c.SendRanges(strings.NewReader(large), len(large), MIMETextPlain))
+	utils.AssertEqual(t, StatusPartialContent, c.Response().StatusCode())
+	app.ReleaseCtx(c)
+	c = app.AcquireCtx(&fasthttp.RequestCtx{})
+	c.Request().Header.Set(HeaderRange, "bytes="+strings.Join(ranges, ","))
+	utils.AssertEqual(t, nil, c.SendRanges(strings.NewReader(large), len(large), MIMETextPlain))
+	utils.AssertEqual(t, StatusOK, c.Response().StatusCode())
+	utils.AssertEqual(t, large, string(c.Response().Body()))
+	app.ReleaseCtx(c)
+}
+
+// go test -run Test_Ctx_SendFile_Ranges
+func Test_Ctx_SendFile_Ranges(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	c.Request().Header.Set(HeaderRange, "bytes=0-1,10-11")
+	utils.AssertEqual(t, nil, c.SendFile("./.github/index.html"))
+	utils.AssertEqual(t, StatusPartialContent, c.Response().StatusCode())
+	utils.AssertEqual(t, true, strings.Contains(string(c.Response().Body()), "Content-Type: text/html"))
+	utils.AssertEqual(t, true, len(c.Response().Header.Peek(HeaderLastModified)) > 0)
+}
+
 // go test -run Test_Ctx_SendStatus
 func Test_Ctx_SendStatus(t *testing.T) {
 	t.Parallel()
@@ -1701,6 +2254,205 @@ func Test_Ctx_SendStream(t *testing.T) {
 	utils.AssertEqual(t, true, (c.Response().Header.ContentLength() > 200))
 }
 
+// go test -run Test_Ctx_SendStreamWriter
+func Test_Ctx_SendStreamWriter(t *testing.T) {
+	t.Parallel()
+	app := New(Config{ETag: true})
+	app.Get("/", func(c *Ctx) error {
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(w, "row %d\n", i)
+				utils.AssertEqual(t, nil, w.Flush())
+			}
+		})
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, []string{"chunked"}, resp.TransferEncoding)
+	utils.AssertEqual(t, "", resp.Header.Get(HeaderETag))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "row 0\nrow 1\nrow 2\n", string(body))
+}
+
+// go test -run Test_Ctx_NextRoutes
+func Test_Ctx_NextRoutes(t *testing.T) {
+	t.Parallel()
+	app := New()
+	app.Use(func(c *Ctx) error {
+		var paths []string
+		for _, r := range c.NextRoutes() {
+			paths = append(paths, r.Path)
+		}
+		utils.AssertEqual(t, []string{"/api", "/api/:id"}, paths)
+		return c.Next()
+	})
+	app.Use("/api", func(c *Ctx) error {
+		return c.Next()
+	})
+	app.Use("/admin", func(c *Ctx) error {
+		return c.Next()
+	})
+	app.Get("/api/:id", func(c *Ctx) error {
+		utils.AssertEqual(t, 0, len(c.NextRoutes()))
+		return c.SendStatus(StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/api/1", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+}
+
+// go test -run Test_Ctx_OnStreamSent
+func Test_Ctx_OnStreamSent(t *testing.T) {
+	t.Parallel()
+	app := New()
+	var sent int64 = -1
+	app.Get("/", func(c *Ctx) error {
+		utils.AssertEqual(t, false, c.OnStreamSent(func(n int64, err error) {}))
+		if err := c.SendStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("streamed")
+		}); err != nil {
+			return err
+		}
+		utils.AssertEqual(t, true, c.OnStreamSent(func(n int64, err error) {
+			utils.AssertEqual(t, nil, err)
+			sent = n
+		}))
+		return nil
+	})
+	app.Get("/body", func(c *Ctx) error {
+		_ = c.SendStreamWriter(func(w *bufio.Writer) {})
+		_ = c.SendString("replaced")
+		utils.AssertEqual(t, false, c.OnStreamSent(func(n int64, err error) {}))
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "streamed", string(body))
+	utils.AssertEqual(t, int64(len("streamed")), sent)
+
+	_, err = app.Test(httptest.NewRequest(MethodGet, "/body", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+}
+
+// go test -run Test_Ctx_SSE
+func Test_Ctx_SSE(t *testing.T) {
+	t.Parallel()
+	app := New()
+	app.Get("/events", func(c *Ctx) error {
+		return c.SSE(func(w *SSEWriter) error {
+			if err := w.Send(SSEEvent{ID: "1", Event: "progress", Data: "resume " + w.LastEventID()}); err != nil {
+				return err
+			}
+			return w.Send(SSEEvent{Data: "line1\nline2", Retry: 3 * time.Second})
+		}, -1)
+	})
+
+	req := httptest.NewRequest(MethodGet, "/events", nil)
+	req.Header.Set(HeaderLastEventID, "42")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, MIMETextEventStream, resp.Header.Get(HeaderContentType))
+	utils.AssertEqual(t, "no-cache", resp.Header.Get(HeaderCacheControl))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, ": ok\n\nid: 1\nevent: progress\ndata: resume 42\n\nretry: 3000\ndata: line1\ndata: line2\n\n", string(body))
+}
+
+// go test -run Test_Ctx_SSE_Heartbeat
+func Test_Ctx_SSE_Heartbeat(t *testing.T) {
+	t.Parallel()
+	app := New()
+	app.Get("/events", func(c *Ctx) error {
+		return c.SSE(func(w *SSEWriter) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, 10*time.Millisecond)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/events", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, strings.Contains(string(body), ": heartbeat\n\n"))
+}
+
+// go test -run Test_Ctx_SSE_Error
+func Test_Ctx_SSE_Error(t *testing.T) {
+	t.Parallel()
+	app := New()
+	errs := make(chan error, 1)
+	app.Get("/events", func(c *Ctx) error {
+		if err := c.SSE(func(w *SSEWriter) error {
+			_ = w.Send(SSEEvent{Data: "partial"})
+			return errors.New("boom")
+		}, -1); err != nil {
+			return err
+		}
+		utils.AssertEqual(t, true, c.OnStreamSent(func(n int64, err error) {
+			errs <- err
+		}))
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/events", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, ": ok\n\ndata: partial\n\n", string(body))
+	utils.AssertEqual(t, "boom", (<-errs).Error())
+}
+
+// go test -run Test_Ctx_SSE_Disconnect
+func Test_Ctx_SSE_Disconnect(t *testing.T) {
+	t.Parallel()
+	app := New(Config{
+		DisableStartupMessage: true,
+	})
+	done := make(chan bool, 1)
+	app.Get("/events", func(c *Ctx) error {
+		// Without heartbeat nothing is written while the stream is idle
+		return c.SSE(func(w *SSEWriter) error {
+			select {
+			case <-w.Done():
+				done <- true
+			case <-time.After(2 * time.Second):
+				done <- false
+			}
+			return nil
+		}, -1)
+	})
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	utils.AssertEqual(t, nil, err)
+	_, err = conn.Write([]byte("GET /events HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	utils.AssertEqual(t, nil, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, MIMETextEventStream, resp.Header.Get(HeaderContentType))
+	utils.AssertEqual(t, nil, conn.Close())
+	utils.AssertEqual(t, true, <-done)
+}
+
 // go test -run Test_Ctx_Set
 func Test_Ctx_Set(t *testing.T) {
 	t.Parallel()
@@ -1759,6 +2511,38 @@ func Test_Ctx_Status(t *testing.T) {
 	utils.AssertEqual(t, "Hello, World", string(c.Response().Body()))
 }
 
+// go test -run Test_Ctx_TakeBodyStream
+func Test_Ctx_TakeBodyStream(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	utils.AssertEqual(t, nil, c.TakeBodyStream())
+
+	// The stream isn't closed when it is taken
+	file, err := os.Open("./.github/index.html")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, c.SendStream(file, 10))
+	stream := c.TakeBodyStream()
+	utils.AssertEqual(t, true, stream != nil)
+	utils.AssertEqual(t, false, c.Response().IsBodyStream())
+	utils.AssertEqual(t, nil, c.TakeBodyStream())
+	body, err := ioutil.ReadAll(stream)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(body) > 200)
+	utils.AssertEqual(t, nil, stream.Close())
+	utils.AssertEqual(t, true, file.Close() != nil)
+
+	// A replaced stream can't be taken
+	utils.AssertEqual(t, nil, c.SendStream(strings.NewReader("stream"), 6))
+	c.Response().SetBodyStream(strings.NewReader("other"), 5)
+	utils.AssertEqual(t, nil, c.TakeBodyStream())
+	utils.AssertEqual(t, nil, c.SendStream(strings.NewReader("stream"), 6))
+	utils.AssertEqual(t, nil, c.SendString("body"))
+	utils.AssertEqual(t, nil, c.TakeBodyStream())
+}
+
 // go test -run Test_Ctx_Type
 func Test_Ctx_Type(t *testing.T) {
 	t.Parallel()
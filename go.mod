@@ -3,7 +3,8 @@ module github.com/gofiber/fiber/v2
 go 1.14
 
 require (
-	github.com/klauspost/compress v1.11.0 // indirect
-	github.com/valyala/fasthttp v1.16.0
-	golang.org/x/sys v0.0.0-20200909081042-eff7692f9009
+	github.com/andybalholm/brotli v1.0.2
+	github.com/klauspost/compress v1.13.4
+	github.com/valyala/fasthttp v1.32.0
+	golang.org/x/sys v0.0.0-20210514084401-e8d321eab015
 )
@@ -6,7 +6,10 @@ package fiber
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"hash/crc32"
 	"io"
@@ -14,7 +17,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -85,6 +90,209 @@ func readContent(rf io.ReaderFrom, name string) (n int64, err error) {
 	return rf.ReadFrom(f)
 }
 
+// limitedReader returns ErrRequestEntityTooLarge once more than max bytes are read
+type limitedReader struct {
+	r        io.Reader
+	left     int
+	onExceed func() // Called when the limit is exceeded
+}
+
+func newLimitedReader(r io.Reader, max, contentLength int) *limitedReader {
+	// Reject a body that announces its size up front
+	if contentLength > max {
+		return &limitedReader{r: r, left: -1}
+	}
+	return &limitedReader{r: r, left: max}
+}
+
+func (lr *limitedReader) Read(p []byte) (n int, err error) {
+	if lr.left < 0 {
+		return 0, lr.exceeded()
+	}
+	// Read one byte past the limit to detect an oversized body
+	if len(p) > lr.left+1 {
+		p = p[:lr.left+1]
+	}
+	n, err = lr.r.Read(p)
+	if n > lr.left {
+		// Return the bytes within the limit before the error
+		n, lr.left = lr.left, -1
+		return n, lr.exceeded()
+	}
+	lr.left -= n
+	return n, err
+}
+
+func (lr *limitedReader) exceeded() error {
+	if lr.onExceed != nil {
+		lr.onExceed()
+	}
+	return ErrRequestEntityTooLarge
+}
+
+// errReader returns err on every read
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// rangeReader reads n bytes from offset of a shared io.ReadSeeker,
+// it only seeks on the first read so several ranges can be chained
+type rangeReader struct {
+	rs     io.ReadSeeker
+	closer io.Reader
+	offset int64
+	n      int64
+	seeked bool
+}
+
+func newRangeReader(rs io.ReadSeeker, closer io.Reader, offset, n int) *rangeReader {
+	return &rangeReader{rs: rs, closer: closer, offset: int64(offset), n: int64(n)}
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if !r.seeked {
+		if _, err := r.rs.Seek(r.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		r.seeked = true
+	}
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.rs.Read(p)
+	r.n -= int64(n)
+	return n, err
+}
+
+// Close closes the underlying reader if the range owns it
+func (r *rangeReader) Close() error {
+	if c, ok := r.closer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// streamSentKey is the user value key of the streamSent of a response
+const streamSentKey = "fiber.streamSent"
+
+// countingReader counts the bytes read from a response body stream
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	if c, ok := r.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// streamSent calls the OnStreamSent functions, it is stored as user value
+// because fasthttp closes those after the response has been written
+type streamSent struct {
+	body *countingReader
+	fns  []func(n int64, err error)
+	// The error of the SSE handler, set by the stream writer goroutine
+	mutex sync.Mutex
+	err   error
+}
+
+func (s *streamSent) setErr(err error) {
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+}
+
+func (s *streamSent) Close() error {
+	s.mutex.Lock()
+	err := s.err
+	s.mutex.Unlock()
+	for _, fn := range s.fns {
+		fn(s.body.n, err)
+	}
+	return nil
+}
+
+// bodyStream is a response body stream set by the Ctx, fasthttp closes it
+// when the body is replaced or has been written
+type bodyStream struct {
+	io.Reader
+	closed bool
+	taken  bool
+}
+
+func (s *bodyStream) Close() error {
+	if s.taken || s.closed {
+		return nil
+	}
+	s.closed = true
+	if c, ok := s.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// readCloser closes the given reader once the wrapped reader is consumed
+type readCloser struct {
+	io.Reader
+	closer io.Reader
+}
+
+func (rc *readCloser) Close() error {
+	if c, ok := rc.closer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// maxRanges is the maximum number of ranges SendRanges answers with a
+// multipart response, the whole content is sent for more ranges
+const maxRanges = 100
+
+// sumRanges returns the total length of the byte ranges
+func sumRanges(ranges []struct{ Start, End int }) (sum int) {
+	for _, r := range ranges {
+		sum += r.End - r.Start + 1
+	}
+	return sum
+}
+
+// coalesceRanges sorts byte ranges and merges the ones that overlap or touch
+func coalesceRanges(ranges []struct{ Start, End int }) []struct{ Start, End int } {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
 // quoteString escape special characters in a given string
 func quoteString(raw string) string {
 	bb := bytebufferpool.Get()
@@ -190,6 +398,10 @@ func setETag(c *Ctx, weak bool) {
 	if c.fasthttp.Response.StatusCode() != StatusOK {
 		return
 	}
+	// Don't buffer streamed responses to hash them
+	if c.fasthttp.Response.IsBodyStream() {
+		return
+	}
 	body := c.fasthttp.Response.Body()
 	// Skips ETag if no response body is present
 	if len(body) <= 0 {
@@ -307,6 +519,59 @@ func isEtagStale(etag string, noneMatchBytes []byte) bool {
 	return !matchEtag(getString(noneMatchBytes[start:end]), etag)
 }
 
+// signCookie appends a signature of the cookie name and value to the value
+func signCookie(name, value, secret string) string {
+	return value + "." + cookieSignature(name, value, secret)
+}
+
+// unsignCookie returns the value of a signed cookie if it carries a valid
+// signature for any of the secrets
+func unsignCookie(name, signed string, secrets []string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i == -1 {
+		return "", false
+	}
+	value, signature := signed[:i], signed[i+1:]
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(signature), []byte(cookieSignature(name, value, secret))) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func cookieSignature(name, value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(name))
+	_, _ = mac.Write([]byte{'='})
+	_, _ = mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// etagListMatch reports whether a comma separated list of entity tags from
+// If-Match or If-None-Match contains etag, "*" matches any existing resource.
+// If-None-Match uses the weak comparison, If-Match the strong one.
+// https://tools.ietf.org/html/rfc7232#section-2.3.2
+func etagListMatch(header, etag string, exists, weak bool) bool {
+	if utils.Trim(header, ' ') == "*" {
+		return exists
+	}
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = utils.Trim(tag, ' ')
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if tag == etag && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
 func isIPv6(address string) bool {
 	return strings.Count(address, ":") >= 2
 }
@@ -448,6 +713,7 @@ const (
 	MIMEApplicationForm       = "application/x-www-form-urlencoded"
 	MIMEOctetStream           = "application/octet-stream"
 	MIMEMultipartForm         = "multipart/form-data"
+	MIMETextEventStream       = "text/event-stream"
 
 	MIMETextXMLCharsetUTF8               = "text/xml; charset=utf-8"
 	MIMETextHTMLCharsetUTF8              = "text/html; charset=utf-8"
@@ -657,6 +923,7 @@ const (
 	HeaderXFrameOptions                   = "X-Frame-Options"
 	HeaderXPoweredBy                      = "X-Powered-By"
 	HeaderXXSSProtection                  = "X-XSS-Protection"
+	HeaderXAccelBuffering                 = "X-Accel-Buffering"
 	HeaderLastEventID                     = "Last-Event-ID"
 	HeaderNEL                             = "NEL"
 	HeaderPingFrom                        = "Ping-From"
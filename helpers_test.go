@@ -7,7 +7,9 @@ package fiber
 import (
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -344,3 +346,27 @@ func Test_Utils_lnMetadata(t *testing.T) {
 		utils.AssertEqual(t, true, config != nil)
 	})
 }
+
+// go test -run Test_Utils_LimitedReader
+func Test_Utils_LimitedReader(t *testing.T) {
+	t.Parallel()
+	exceeded := false
+	lr := newLimitedReader(strings.NewReader("john=doe"), 4, -1)
+	lr.onExceed = func() {
+		exceeded = true
+	}
+	// The bytes within the limit are returned before the error
+	b, err := ioutil.ReadAll(lr)
+	utils.AssertEqual(t, ErrRequestEntityTooLarge, err)
+	utils.AssertEqual(t, "john", string(b))
+	utils.AssertEqual(t, true, exceeded)
+
+	// A body that announces a larger size is rejected up front
+	b, err = ioutil.ReadAll(newLimitedReader(strings.NewReader("john=doe"), 4, 8))
+	utils.AssertEqual(t, ErrRequestEntityTooLarge, err)
+	utils.AssertEqual(t, "", string(b))
+
+	b, err = ioutil.ReadAll(newLimitedReader(strings.NewReader("john=doe"), 8, 8))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "john=doe", string(b))
+}
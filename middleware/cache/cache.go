@@ -0,0 +1,330 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// Expiration is the time that a cached response will live when the
+	// response has no Cache-Control max-age or s-maxage directive.
+	//
+	// Optional. Default: 1 * time.Minute
+	Expiration time.Duration
+
+	// CacheHeader is the header on the response which indicates the cache
+	// status, with the possible values "hit" and "miss".
+	//
+	// Optional. Default: X-Cache
+	CacheHeader string
+
+	// KeyGenerator allows you to generate custom keys, the request method is
+	// always part of the key. The returned string is copied.
+	//
+	// Optional. Default: func(c *fiber.Ctx) string {
+	//   return c.OriginalURL()
+	// }
+	KeyGenerator func(*fiber.Ctx) string
+
+	// VaryBy is a list of request headers whose values are always part of
+	// the key, in addition to the headers listed in the Vary response header.
+	//
+	// Optional. Default: nil
+	VaryBy []string
+
+	// Invalidator allows to remove cached responses by key or prefix.
+	//
+	// Optional. Default: nil
+	Invalidator *Invalidator
+
+	// Storage is used to store the cached responses.
+	//
+	// Optional. Default: an in-memory storage
+	Storage fiber.Storage
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:        nil,
+	Expiration:  1 * time.Minute,
+	CacheHeader: "X-Cache",
+	KeyGenerator: func(c *fiber.Ctx) string {
+		return c.OriginalURL()
+	},
+}
+
+// cache status values
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// New creates a new middleware handler
+func New(config ...Config) fiber.Handler {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Next == nil {
+			cfg.Next = ConfigDefault.Next
+		}
+		if int(cfg.Expiration.Seconds()) <= 0 {
+			cfg.Expiration = ConfigDefault.Expiration
+		}
+		if cfg.CacheHeader == "" {
+			cfg.CacheHeader = ConfigDefault.CacheHeader
+		}
+		if cfg.KeyGenerator == nil {
+			cfg.KeyGenerator = ConfigDefault.KeyGenerator
+		}
+	}
+	if cfg.Storage == nil {
+		cfg.Storage = memory.New()
+	}
+	if cfg.Invalidator == nil {
+		cfg.Invalidator = NewInvalidator()
+	}
+	cfg.Invalidator.add(cfg.Storage)
+
+	// Normalize the header names of VaryBy
+	varyBy := make([]string, len(cfg.VaryBy))
+	for i := range cfg.VaryBy {
+		varyBy[i] = utils.ToLower(cfg.VaryBy[i])
+	}
+
+	var (
+		// mutex for the requests in flight
+		mux      sync.Mutex
+		inflight = make(map[string]*sync.WaitGroup)
+	)
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Only cache GET requests, HEAD requests are served from the GET response
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+
+		reqCC := parseCacheControl(c.Get(fiber.HeaderCacheControl))
+
+		// Cache-Control: no-store requests never touch the cache
+		if reqCC.noStore {
+			c.Set(cfg.CacheHeader, cacheMiss)
+			return c.Next()
+		}
+
+		key := fiber.MethodGet + ":" + utils.ImmutableString(cfg.KeyGenerator(c))
+
+		// Cache-Control: no-cache and max-age=0 requests skip the lookup
+		// but still update the cache
+		if !reqCC.noCache && reqCC.maxAge != 0 {
+			served, err := serve(c, &cfg, key, varyBy, reqCC)
+			if served || err != nil {
+				return err
+			}
+
+			// Coalesce concurrent misses into a single call of the handler
+			mux.Lock()
+			if wg, ok := inflight[key]; ok {
+				mux.Unlock()
+				wg.Wait()
+				// The response could be for a different variant or not cacheable
+				if served, err = serve(c, &cfg, key, varyBy, reqCC); served || err != nil {
+					return err
+				}
+			} else {
+				wg = &sync.WaitGroup{}
+				wg.Add(1)
+				inflight[key] = wg
+				mux.Unlock()
+				defer func() {
+					mux.Lock()
+					delete(inflight, key)
+					mux.Unlock()
+					wg.Done()
+				}()
+			}
+		}
+
+		c.Set(cfg.CacheHeader, cacheMiss)
+
+		// Continue stack, errors are handled by the ErrorHandler and not cached
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		// Handlers may leave out the body of HEAD responses
+		if c.Method() != fiber.MethodGet {
+			return nil
+		}
+		return store(c, &cfg, key, varyBy)
+	}
+}
+
+// serve sends the cached response if there is one, the returned bool is true if it did
+func serve(c *fiber.Ctx, cfg *Config, key string, varyBy []string, reqCC cacheControl) (bool, error) {
+	// The meta entry holds the header names the response varies by
+	missed := time.Now().UnixNano()
+	meta, err := cfg.Storage.Get(keyPrefix + key)
+	if err != nil || meta == nil {
+		if err == nil {
+			cfg.Invalidator.forget(key, missed)
+		}
+		return false, err
+	}
+	raw, err := cfg.Storage.Get(keyPrefix + variantKey(c, key, decodeMeta(meta)))
+	if err != nil || raw == nil {
+		return false, err
+	}
+	e, ok := decodeEntry(raw)
+	if !ok {
+		return false, nil
+	}
+
+	// Honor the max-age of the request
+	age := (time.Now().UnixNano() - e.created) / int64(time.Second)
+	if reqCC.maxAge > 0 && age > reqCC.maxAge {
+		return false, nil
+	}
+
+	c.Response().SetStatusCode(e.status)
+	for i := 0; i+1 < len(e.headers); i += 2 {
+		c.Response().Header.Set(e.headers[i], e.headers[i+1])
+	}
+	c.Set(fiber.HeaderAge, strconv.FormatInt(age, 10))
+	c.Set(cfg.CacheHeader, cacheHit)
+
+	// Let the client use its own copy if it is still fresh
+	if e.status == fiber.StatusOK && c.Fresh() {
+		c.Status(fiber.StatusNotModified)
+		c.Response().ResetBody()
+		return true, nil
+	}
+	c.Response().SetBody(e.body)
+	return true, nil
+}
+
+// store caches the response if it is cacheable
+func store(c *fiber.Ctx, cfg *Config, key string, varyBy []string) error {
+	resp := c.Response()
+	if !cacheableStatus[resp.StatusCode()] || resp.IsBodyStream() || len(resp.Header.Peek(fiber.HeaderSetCookie)) > 0 {
+		return nil
+	}
+
+	resCC := parseCacheControl(string(resp.Header.Peek(fiber.HeaderCacheControl)))
+	if resCC.noStore || resCC.noCache || resCC.private {
+		return nil
+	}
+
+	// Responses to authorized requests are only shared when explicitly allowed
+	// https://datatracker.ietf.org/doc/html/rfc7234#section-3.2
+	if c.Get(fiber.HeaderAuthorization) != "" && !resCC.public && !resCC.mustRevalidate && resCC.sMaxAge < 0 {
+		return nil
+	}
+
+	// s-maxage is meant for shared caches and takes precedence over max-age
+	exp := cfg.Expiration
+	if resCC.sMaxAge >= 0 {
+		exp = time.Duration(resCC.sMaxAge) * time.Second
+	} else if resCC.maxAge >= 0 {
+		exp = time.Duration(resCC.maxAge) * time.Second
+	}
+	if exp <= 0 {
+		return nil
+	}
+
+	// Add the request headers named by the Vary response header to the key
+	vary := append([]string(nil), varyBy...)
+	for _, name := range strings.Split(string(resp.Header.Peek(fiber.HeaderVary)), ",") {
+		name = utils.ToLower(utils.Trim(name, ' '))
+		if name == "*" {
+			return nil
+		}
+		if name != "" {
+			vary = append(vary, name)
+		}
+	}
+	sort.Strings(vary)
+
+	e := entry{
+		status:  resp.StatusCode(),
+		created: time.Now().UnixNano(),
+		body:    resp.Body(),
+	}
+	resp.Header.VisitAll(func(k, v []byte) {
+		if !skipHeaders[utils.ToLower(string(k))] {
+			e.headers = append(e.headers, string(k), string(v))
+		}
+	})
+
+	variant := variantKey(c, key, vary)
+	if err := cfg.Storage.Set(keyPrefix+key, encodeMeta(vary), exp); err != nil {
+		return err
+	}
+	if err := cfg.Storage.Set(keyPrefix+variant, e.encode(), exp); err != nil {
+		return err
+	}
+	cfg.Invalidator.track(key, variant, exp)
+	return nil
+}
+
+// variantKey adds the values of the vary headers to the key
+func variantKey(c *fiber.Ctx, key string, vary []string) string {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteByte('|')
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(c.Get(name))
+		b.WriteByte('|')
+	}
+	return b.String()
+}
+
+// Status codes that are cacheable by default
+// https://tools.ietf.org/html/rfc7231#section-6.1
+var cacheableStatus = map[int]bool{
+	fiber.StatusOK:                          true,
+	fiber.StatusNonAuthoritativeInformation: true,
+	fiber.StatusNoContent:                   true,
+	fiber.StatusMultipleChoices:             true,
+	fiber.StatusMovedPermanently:            true,
+	fiber.StatusNotFound:                    true,
+	fiber.StatusMethodNotAllowed:            true,
+	fiber.StatusGone:                        true,
+	fiber.StatusRequestURITooLong:           true,
+	fiber.StatusNotImplemented:              true,
+}
+
+// Headers that are not stored with a cached response
+var skipHeaders = map[string]bool{
+	"age":            true,
+	"connection":     true,
+	"content-length": true,
+	"date":           true,
+	"server":         true,
+	"x-cache":        true,
+}
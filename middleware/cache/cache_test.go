@@ -0,0 +1,461 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_Cache
+func Test_Cache(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	var count int32
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set("X-Custom", "value")
+		return c.SendString(strconv.Itoa(int(atomic.AddInt32(&count, 1))))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "miss", resp.Header.Get("X-Cache"))
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "1", string(body))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "hit", resp.Header.Get("X-Cache"))
+	utils.AssertEqual(t, "value", resp.Header.Get("X-Custom"))
+	utils.AssertEqual(t, fiber.MIMETextPlainCharsetUTF8, resp.Header.Get(fiber.HeaderContentType))
+	utils.AssertEqual(t, "0", resp.Header.Get(fiber.HeaderAge))
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "1", string(body))
+
+	// HEAD requests are served from the GET response
+	resp, err = app.Test(httptest.NewRequest("HEAD", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "hit", resp.Header.Get("X-Cache"))
+
+	// The query string is part of the key
+	resp, err = app.Test(httptest.NewRequest("GET", "/?q=a", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "miss", resp.Header.Get("X-Cache"))
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "2", string(body))
+
+	// Other methods are not cached
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendString("post")
+	})
+	resp, err = app.Test(httptest.NewRequest("POST", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", resp.Header.Get("X-Cache"))
+}
+
+// go test -run Test_Cache_Request_CacheControl
+func Test_Cache_Request_CacheControl(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	var count int32
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(int(atomic.AddInt32(&count, 1))))
+	})
+
+	request := func(cacheControl string) (string, string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if cacheControl != "" {
+			req.Header.Set(fiber.HeaderCacheControl, cacheControl)
+		}
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		return resp.Header.Get("X-Cache"), string(body)
+	}
+
+	// no-store neither reads nor writes the cache
+	status, body := request("no-store")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "1", body)
+	status, body = request("")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "2", body)
+	status, body = request("")
+	utils.AssertEqual(t, "hit", status)
+	utils.AssertEqual(t, "2", body)
+
+	// no-cache and max-age=0 update the cache
+	status, body = request("no-cache")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "3", body)
+	status, body = request("max-age=0")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "4", body)
+	status, body = request("max-age=60")
+	utils.AssertEqual(t, "hit", status)
+	utils.AssertEqual(t, "4", body)
+}
+
+// go test -run Test_Cache_Response_CacheControl
+func Test_Cache_Response_CacheControl(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{Expiration: time.Hour}))
+
+	var count int32
+	app.Get("/:cc", func(c *fiber.Ctx) error {
+		switch c.Params("cc") {
+		case "private":
+			c.Set(fiber.HeaderCacheControl, "private, max-age=60")
+		case "no-store":
+			c.Set(fiber.HeaderCacheControl, "no-store")
+		case "max-age":
+			c.Set(fiber.HeaderCacheControl, "public, max-age=1")
+		case "s-maxage":
+			c.Set(fiber.HeaderCacheControl, "max-age=3600, s-maxage=1")
+		case "cookie":
+			c.Cookie(&fiber.Cookie{Name: "id", Value: "1"})
+		case "error":
+			return fiber.ErrBadGateway
+		}
+		return c.SendString(strconv.Itoa(int(atomic.AddInt32(&count, 1))))
+	})
+
+	request := func(path string) string {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		utils.AssertEqual(t, nil, err)
+		return resp.Header.Get("X-Cache")
+	}
+
+	for _, path := range []string{"/private", "/no-store", "/cookie", "/error"} {
+		utils.AssertEqual(t, "miss", request(path), path)
+		utils.AssertEqual(t, "miss", request(path), path)
+	}
+
+	utils.AssertEqual(t, "miss", request("/max-age"))
+	utils.AssertEqual(t, "miss", request("/s-maxage"))
+	utils.AssertEqual(t, "hit", request("/max-age"))
+	utils.AssertEqual(t, "hit", request("/s-maxage"))
+
+	time.Sleep(1100 * time.Millisecond)
+
+	utils.AssertEqual(t, "miss", request("/max-age"))
+	utils.AssertEqual(t, "miss", request("/s-maxage"))
+}
+
+// go test -run Test_Cache_Authorization
+func Test_Cache_Authorization(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/:mode", func(c *fiber.Ctx) error {
+		switch c.Params("mode") {
+		case "public":
+			c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+		case "s-maxage":
+			c.Set(fiber.HeaderCacheControl, "s-maxage=60")
+		case "must-revalidate":
+			c.Set(fiber.HeaderCacheControl, "must-revalidate")
+		case "max-age":
+			c.Set(fiber.HeaderCacheControl, "max-age=60")
+		}
+		return c.SendString(c.Get(fiber.HeaderAuthorization))
+	})
+
+	request := func(path, auth string) (string, string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, auth)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		return resp.Header.Get("X-Cache"), string(body)
+	}
+
+	// Per user responses are not shared
+	for _, path := range []string{"/default", "/max-age"} {
+		status, body := request(path, "Bearer alice")
+		utils.AssertEqual(t, "miss", status, path)
+		utils.AssertEqual(t, "Bearer alice", body, path)
+		status, body = request(path, "Bearer bob")
+		utils.AssertEqual(t, "miss", status, path)
+		utils.AssertEqual(t, "Bearer bob", body, path)
+	}
+
+	// Unless the response allows it
+	for _, path := range []string{"/public", "/s-maxage", "/must-revalidate"} {
+		status, _ := request(path, "Bearer alice")
+		utils.AssertEqual(t, "miss", status, path)
+		status, body := request(path, "Bearer bob")
+		utils.AssertEqual(t, "hit", status, path)
+		utils.AssertEqual(t, "Bearer alice", body, path)
+	}
+}
+
+// go test -run Test_Cache_Vary
+func Test_Cache_Vary(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{VaryBy: []string{"X-Tenant"}}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Vary(fiber.HeaderAcceptLanguage)
+		return c.SendString(c.Get("X-Tenant") + ":" + c.Get(fiber.HeaderAcceptLanguage))
+	})
+
+	request := func(tenant, lang string) (string, string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		req.Header.Set(fiber.HeaderAcceptLanguage, lang)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		return resp.Header.Get("X-Cache"), string(body)
+	}
+
+	status, body := request("a", "en")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "a:en", body)
+
+	status, body = request("a", "nl")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "a:nl", body)
+
+	status, body = request("b", "en")
+	utils.AssertEqual(t, "miss", status)
+	utils.AssertEqual(t, "b:en", body)
+
+	status, body = request("a", "en")
+	utils.AssertEqual(t, "hit", status)
+	utils.AssertEqual(t, "a:en", body)
+}
+
+// go test -run Test_Cache_NotModified
+func Test_Cache_NotModified(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderETag, `"v1"`)
+		return c.SendString("Hello, World!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "miss", resp.Header.Get("X-Cache"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"v1"`)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "hit", resp.Header.Get("X-Cache"))
+	utils.AssertEqual(t, fiber.StatusNotModified, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", string(body))
+}
+
+// go test -run Test_Cache_Coalescing
+func Test_Cache_Coalescing(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	var count int32
+	app.Get("/", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(100 * time.Millisecond)
+		return c.SendString("Hello, World!")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil || resp.StatusCode != fiber.StatusOK {
+				atomic.AddInt32(&count, 100)
+			}
+		}()
+	}
+	wg.Wait()
+
+	utils.AssertEqual(t, int32(1), atomic.LoadInt32(&count))
+}
+
+// go test -run Test_Cache_Invalidator
+func Test_Cache_Invalidator(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	invalidator := NewInvalidator()
+
+	app.Use(New(Config{Invalidator: invalidator}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString(c.Path())
+	})
+
+	request := func(path string) string {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		utils.AssertEqual(t, nil, err)
+		return resp.Header.Get("X-Cache")
+	}
+
+	for _, path := range []string{"/users/1", "/users/2", "/posts/1"} {
+		utils.AssertEqual(t, "miss", request(path))
+		utils.AssertEqual(t, "hit", request(path))
+	}
+
+	utils.AssertEqual(t, nil, invalidator.Delete("GET:/users/1"))
+	utils.AssertEqual(t, "miss", request("/users/1"))
+	utils.AssertEqual(t, "hit", request("/users/2"))
+
+	utils.AssertEqual(t, nil, invalidator.DeletePrefix("GET:/users/"))
+	utils.AssertEqual(t, "miss", request("/users/1"))
+	utils.AssertEqual(t, "miss", request("/users/2"))
+	utils.AssertEqual(t, "hit", request("/posts/1"))
+}
+
+// go test -run Test_Cache_Invalidator_Expired
+func Test_Cache_Invalidator_Expired(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	invalidator := NewInvalidator()
+	storage := memory.New()
+
+	app.Use(New(Config{Invalidator: invalidator, Storage: storage}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString(c.Path())
+	})
+
+	request := func(path string) {
+		_, err := app.Test(httptest.NewRequest("GET", path, nil))
+		utils.AssertEqual(t, nil, err)
+	}
+	variants := func(key string) int {
+		invalidator.mutex.Lock()
+		defer invalidator.mutex.Unlock()
+		return len(invalidator.keys[key])
+	}
+
+	request("/a")
+	request("/b")
+	utils.AssertEqual(t, 1, variants("GET:/a"))
+	utils.AssertEqual(t, 1, variants("GET:/b"))
+
+	// A storage miss forgets the key
+	utils.AssertEqual(t, nil, storage.Reset())
+	invalidator.mutex.Lock()
+	invalidator.keys["GET:/a"]["GET:/a|gone"] = tracked{}
+	invalidator.mutex.Unlock()
+	request("/a")
+	utils.AssertEqual(t, 1, variants("GET:/a"))
+
+	// Expired keys are pruned when a key is stored
+	invalidator.mutex.Lock()
+	for _, tracks := range invalidator.keys {
+		for variant := range tracks {
+			tracks[variant] = tracked{}
+		}
+	}
+	invalidator.nextPrune = 0
+	invalidator.mutex.Unlock()
+	request("/c")
+	utils.AssertEqual(t, 0, variants("GET:/a"))
+	utils.AssertEqual(t, 0, variants("GET:/b"))
+	utils.AssertEqual(t, 1, variants("GET:/c"))
+}
+
+// go test -run Test_Cache_Next
+func Test_Cache_Next(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Next: func(_ *fiber.Ctx) bool {
+			return true
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", resp.Header.Get("X-Cache"))
+}
+
+// go test -run Test_Cache_parseCacheControl
+func Test_Cache_parseCacheControl(t *testing.T) {
+	t.Parallel()
+	cc := parseCacheControl(`public, Max-Age="60", s-maxage=10, no-cache`)
+	utils.AssertEqual(t, int64(60), cc.maxAge)
+	utils.AssertEqual(t, int64(10), cc.sMaxAge)
+	utils.AssertEqual(t, true, cc.noCache)
+	utils.AssertEqual(t, false, cc.noStore)
+	utils.AssertEqual(t, true, cc.public)
+	utils.AssertEqual(t, false, cc.mustRevalidate)
+
+	cc = parseCacheControl("private, no-store, max-age=abc")
+	utils.AssertEqual(t, int64(-1), cc.maxAge)
+	utils.AssertEqual(t, true, cc.private)
+	utils.AssertEqual(t, true, cc.noStore)
+}
+
+// go test -v -run=^$ -bench=Benchmark_Cache -benchmem -count=4
+func Benchmark_Cache(b *testing.B) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/demo", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod("GET")
+	fctx.Request.SetRequestURI("/demo")
+
+	// Fill the cache
+	h(fctx)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+
+	utils.AssertEqual(b, "hit", string(fctx.Response.Header.Peek("X-Cache")))
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// keyPrefix separates the cached responses from the keys of other
+// middlewares sharing the storage
+const keyPrefix = "cache:"
+
+// Invalidator removes cached responses, pass it to New with Config.Invalidator.
+// Keys are the method followed by the result of the KeyGenerator, e.g. "GET:/users/1".
+// A key can always be invalidated, invalidating by prefix only finds the
+// keys cached by this process.
+type Invalidator struct {
+	mutex     sync.Mutex
+	storages  []fiber.Storage
+	keys      map[string]map[string]tracked
+	nextPrune int64
+}
+
+// tracked holds when a variant was stored and expires in unix nanoseconds
+type tracked struct {
+	stored int64
+	expiry int64
+}
+
+// pruneInterval is the interval in which expired keys are forgotten
+const pruneInterval = 10 * time.Second
+
+// NewInvalidator creates a new Invalidator
+func NewInvalidator() *Invalidator {
+	return &Invalidator{
+		keys: make(map[string]map[string]tracked),
+	}
+}
+
+// Delete removes the cached responses of key, including all variants
+func (inv *Invalidator) Delete(key string) error {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	return inv.delete(key)
+}
+
+// DeletePrefix removes the cached responses of all keys starting with prefix
+func (inv *Invalidator) DeletePrefix(prefix string) error {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	for key := range inv.keys {
+		if strings.HasPrefix(key, prefix) {
+			if err := inv.delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (inv *Invalidator) delete(key string) error {
+	for _, storage := range inv.storages {
+		// Without the meta entry the variants can't be found anymore
+		if err := storage.Delete(keyPrefix + key); err != nil {
+			return err
+		}
+		for variant := range inv.keys[key] {
+			if err := storage.Delete(keyPrefix + variant); err != nil {
+				return err
+			}
+		}
+	}
+	delete(inv.keys, key)
+	return nil
+}
+
+// add registers the storage of a middleware
+func (inv *Invalidator) add(storage fiber.Storage) {
+	inv.mutex.Lock()
+	inv.storages = append(inv.storages, storage)
+	inv.mutex.Unlock()
+}
+
+// track records a stored variant of key that expires after exp
+func (inv *Invalidator) track(key, variant string, exp time.Duration) {
+	now := time.Now().UnixNano()
+	inv.mutex.Lock()
+	if now >= inv.nextPrune {
+		inv.prune(now)
+		inv.nextPrune = now + int64(pruneInterval)
+	}
+	if inv.keys[key] == nil {
+		inv.keys[key] = make(map[string]tracked)
+	}
+	inv.keys[key][variant] = tracked{stored: now, expiry: now + int64(exp)}
+	inv.mutex.Unlock()
+}
+
+// forget removes the variants of key stored before the storage missed it at
+// time missed, variants stored in the meantime are kept
+func (inv *Invalidator) forget(key string, missed int64) {
+	inv.mutex.Lock()
+	if variants, ok := inv.keys[key]; ok {
+		for variant, t := range variants {
+			if t.stored < missed {
+				delete(variants, variant)
+			}
+		}
+		if len(variants) == 0 {
+			delete(inv.keys, key)
+		}
+	}
+	inv.mutex.Unlock()
+}
+
+// prune removes the expired variants and the keys without variants
+func (inv *Invalidator) prune(now int64) {
+	for key, variants := range inv.keys {
+		for variant, t := range variants {
+			if t.expiry <= now {
+				delete(variants, variant)
+			}
+		}
+		if len(variants) == 0 {
+			delete(inv.keys, key)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// cacheControl holds the directives of a Cache-Control header,
+// -1 means the directive is not present
+type cacheControl struct {
+	noStore        bool
+	noCache        bool
+	private        bool
+	public         bool
+	mustRevalidate bool
+	maxAge         int64
+	sMaxAge        int64
+}
+
+func parseCacheControl(header string) cacheControl {
+	cc := cacheControl{maxAge: -1, sMaxAge: -1}
+	for _, directive := range strings.Split(header, ",") {
+		directive = utils.ToLower(utils.Trim(directive, ' '))
+		name, value := directive, ""
+		if i := strings.IndexByte(directive, '='); i != -1 {
+			name, value = directive[:i], utils.Trim(directive[i+1:], '"')
+		}
+		switch name {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "private":
+			cc.private = true
+		case "public":
+			cc.public = true
+		case "must-revalidate":
+			cc.mustRevalidate = true
+		case "max-age":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+				cc.maxAge = n
+			}
+		case "s-maxage":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+				cc.sMaxAge = n
+			}
+		}
+	}
+	return cc
+}
+
+// entry is a cached response
+type entry struct {
+	status  int
+	created int64
+	headers []string
+	body    []byte
+}
+
+// encode serializes the entry as status, created, header count, the
+// length prefixed headers and the body
+func (e *entry) encode() []byte {
+	size := 3*binary.MaxVarintLen64 + len(e.body)
+	for _, h := range e.headers {
+		size += binary.MaxVarintLen64 + len(h)
+	}
+	b := make([]byte, 0, size)
+	b = appendUvarint(b, uint64(e.status))
+	b = appendUvarint(b, uint64(e.created))
+	b = appendUvarint(b, uint64(len(e.headers)))
+	for _, h := range e.headers {
+		b = appendUvarint(b, uint64(len(h)))
+		b = append(b, h...)
+	}
+	return append(b, e.body...)
+}
+
+func decodeEntry(b []byte) (e entry, ok bool) {
+	var status, created, count uint64
+	if status, b, ok = readUvarint(b); !ok {
+		return e, false
+	}
+	if created, b, ok = readUvarint(b); !ok {
+		return e, false
+	}
+	if count, b, ok = readUvarint(b); !ok || count > uint64(len(b)) {
+		return e, false
+	}
+	e.status, e.created = int(status), int64(created)
+	e.headers = make([]string, count)
+	for i := range e.headers {
+		var n uint64
+		if n, b, ok = readUvarint(b); !ok || n > uint64(len(b)) {
+			return e, false
+		}
+		e.headers[i], b = string(b[:n]), b[n:]
+	}
+	e.body = b
+	return e, true
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func readUvarint(b []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, b, false
+	}
+	return v, b[n:], true
+}
+
+// encodeMeta serializes the vary header names, the prefix keeps the value non-empty
+func encodeMeta(vary []string) []byte {
+	return []byte("vary:" + strings.Join(vary, ","))
+}
+
+func decodeMeta(b []byte) []string {
+	s := strings.TrimPrefix(string(b), "vary:")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
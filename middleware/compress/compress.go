@@ -1,7 +1,17 @@
 package compress
 
 import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/valyala/fasthttp"
 )
 
@@ -20,12 +30,61 @@ type Config struct {
 	// LevelBestSpeed:        1
 	// LevelBestCompression:  2
 	Level int
+
+	// MinLength is the minimum size in bytes of a response body to compress,
+	// streams with an unknown size are always compressed. Zero uses the
+	// default, a negative value compresses bodies of any size.
+	//
+	// Optional. Default: 200
+	MinLength int
+
+	// ContentTypes restricts compression to the responses with one of the
+	// content types, "text/*" matches all text types.
+	//
+	// Optional. Default: nil
+	ContentTypes []string
+
+	// ExcludeContentTypes are the content types that are never compressed,
+	// such as images and archives which are compressed already.
+	//
+	// Optional. Default: DefaultExcludeContentTypes
+	ExcludeContentTypes []string
+
+	// Encodings are the supported encodings in order of preference, the
+	// client's preference in the Accept-Encoding header takes precedence.
+	//
+	// Optional. Default: []string{"br", "zstd", "gzip", "deflate"}
+	Encodings []string
+}
+
+// DefaultExcludeContentTypes are content types that don't benefit from compression
+var DefaultExcludeContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next:  nil,
-	Level: LevelDefault,
+	Next:                nil,
+	Level:               LevelDefault,
+	MinLength:           200,
+	ExcludeContentTypes: DefaultExcludeContentTypes,
+	Encodings:           []string{encodingBrotli, encodingZstd, encodingGzip, encodingDeflate},
 }
 
 // Compression levels
@@ -36,6 +95,14 @@ const (
 	LevelBestCompression = 2
 )
 
+// Content codings
+const (
+	encodingBrotli  = "br"
+	encodingZstd    = "zstd"
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
 	// Set default config
@@ -52,25 +119,30 @@ func New(config ...Config) fiber.Handler {
 		if cfg.Level < -1 || cfg.Level > 2 {
 			cfg.Level = ConfigDefault.Level
 		}
+		if cfg.MinLength == 0 {
+			cfg.MinLength = ConfigDefault.MinLength
+		}
+		if cfg.ExcludeContentTypes == nil {
+			cfg.ExcludeContentTypes = ConfigDefault.ExcludeContentTypes
+		}
+		if len(cfg.Encodings) == 0 {
+			cfg.Encodings = ConfigDefault.Encodings
+		}
 	}
 
-	// Setup request handlers
+	// Setup compression levels
 	var (
-		fctx       = func(c *fasthttp.RequestCtx) {}
-		compressor fasthttp.RequestHandler
+		brotliLevel int
+		otherLevel  int
+		zstdLevel   zstd.EncoderLevel
 	)
-
-	// Setup compression algorithm
 	switch cfg.Level {
-	case 0:
-		// LevelDefault
-		compressor = fasthttp.CompressHandlerBrotliLevel(fctx, fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression)
-	case 1:
-		// LevelBestSpeed
-		compressor = fasthttp.CompressHandlerBrotliLevel(fctx, fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed)
-	case 2:
-		// LevelBestCompression
-		compressor = fasthttp.CompressHandlerBrotliLevel(fctx, fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression)
+	case LevelDefault:
+		brotliLevel, otherLevel, zstdLevel = fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression, zstd.SpeedDefault
+	case LevelBestSpeed:
+		brotliLevel, otherLevel, zstdLevel = fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed, zstd.SpeedFastest
+	case LevelBestCompression:
+		brotliLevel, otherLevel, zstdLevel = fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression, zstd.SpeedBestCompression
 	default:
 		// LevelDisabled
 		return func(c *fiber.Ctx) error {
@@ -78,6 +150,13 @@ func New(config ...Config) fiber.Handler {
 		}
 	}
 
+	// The encoder is safe for concurrent use with EncodeAll, browsers
+	// support windows up to 8 MB
+	zstdEncoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel), zstd.WithWindowSize(8<<20))
+	if err != nil {
+		panic(err)
+	}
+
 	// Return new handler
 	return func(c *fiber.Ctx) error {
 		// Don't execute middleware if Next returns true
@@ -90,10 +169,139 @@ func New(config ...Config) fiber.Handler {
 			return err
 		}
 
+		resp := c.Response()
+
+		// Don't compress twice or a body that doesn't compress well
+		if len(resp.Header.Peek(fiber.HeaderContentEncoding)) > 0 || !cfg.compressible(resp.Header.ContentType()) {
+			return nil
+		}
+
+		// The response depends on the Accept-Encoding from here on
+		c.Vary(fiber.HeaderAcceptEncoding)
+
+		if c.Method() == fiber.MethodHead {
+			return nil
+		}
+
+		// Body would read a stream entirely
+		stream := resp.IsBodyStream()
+		size := resp.Header.ContentLength()
+		if !stream {
+			size = len(resp.Body())
+		}
+		if size == 0 || (size > 0 && size < cfg.MinLength) {
+			return nil
+		}
+
+		encoding := utils.NegotiateEncoding(c.Get(fiber.HeaderAcceptEncoding), cfg.Encodings...)
+		if encoding == "" {
+			return nil
+		}
+
 		// Compress response
-		compressor(c.Context())
+		if stream {
+			// Only the streams set by the Ctx can be wrapped
+			body := c.TakeBodyStream()
+			if body == nil {
+				return nil
+			}
+			resp.SetBodyStreamWriter(func(w *bufio.Writer) {
+				defer body.Close()
+				var (
+					enc encoder
+					err error
+				)
+				switch encoding {
+				case encodingBrotli:
+					enc = brotli.NewWriterLevel(w, brotliLevel)
+				case encodingZstd:
+					enc, err = zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel), zstd.WithWindowSize(8<<20), zstd.WithEncoderConcurrency(1))
+				case encodingGzip:
+					enc, err = gzip.NewWriterLevel(w, otherLevel)
+				case encodingDeflate:
+					enc, err = flate.NewWriter(w, otherLevel)
+				}
+				if err != nil {
+					return
+				}
+				compressStream(w, enc, body)
+			})
+		} else {
+			var body []byte
+			switch encoding {
+			case encodingBrotli:
+				body = fasthttp.AppendBrotliBytesLevel(nil, resp.Body(), brotliLevel)
+			case encodingZstd:
+				body = zstdEncoder.EncodeAll(resp.Body(), nil)
+			case encodingGzip:
+				body = fasthttp.AppendGzipBytesLevel(nil, resp.Body(), otherLevel)
+			case encodingDeflate:
+				body = fasthttp.AppendDeflateBytesLevel(nil, resp.Body(), otherLevel)
+			}
+			resp.SetBodyRaw(body)
+		}
+		c.Set(fiber.HeaderContentEncoding, encoding)
+
+		// The compressed body isn't byte for byte the body the entity tag
+		// was made for, so it can only be a weak one
+		if etag := resp.Header.Peek(fiber.HeaderETag); len(etag) > 0 && !bytes.HasPrefix(etag, []byte("W/")) {
+			resp.Header.Set(fiber.HeaderETag, "W/"+string(etag))
+		}
 
 		// Return from handler
 		return nil
 	}
 }
+
+// encoder is a compressing writer of a stream
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressStream compresses body to w, everything read from body is flushed
+// to the client right away so that e.g. events aren't held back
+func compressStream(w *bufio.Writer, enc encoder, body io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := enc.Write(buf[:n]); err != nil {
+				return
+			}
+			if err := enc.Flush(); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	_ = enc.Close()
+}
+
+// compressible returns true if the content type may be compressed
+func (cfg *Config) compressible(contentType []byte) bool {
+	ct := utils.ToLower(strings.TrimSpace(strings.Split(string(contentType), ";")[0]))
+	if len(cfg.ContentTypes) > 0 && !matchContentType(ct, cfg.ContentTypes) {
+		return false
+	}
+	return !matchContentType(ct, cfg.ExcludeContentTypes)
+}
+
+// matchContentType returns true if ct is one of types, which may end with /*
+func matchContentType(ct string, types []string) bool {
+	for _, t := range types {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(ct, t[:len(t)-1]) {
+				return true
+			}
+		} else if ct == t {
+			return true
+		}
+	}
+	return false
+}
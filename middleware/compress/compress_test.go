@@ -1,12 +1,16 @@
 package compress
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
+	"github.com/klauspost/compress/zstd"
 )
 
 var filedata []byte
@@ -95,3 +99,248 @@ func Test_Compress_Brotli(t *testing.T) {
 	}
 	utils.AssertEqual(t, true, len(body) < len(filedata))
 }
+
+// go test -run Test_Compress_Stream
+func Test_Compress_Stream(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.Write(filedata)
+			_ = w.Flush()
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, 200, resp.StatusCode, "Status code")
+	utils.AssertEqual(t, "gzip", resp.Header.Get(fiber.HeaderContentEncoding))
+
+	// Validate the streamed body is compressed
+	zr, err := gzip.NewReader(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	body, err := ioutil.ReadAll(zr)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, filedata, body)
+}
+
+// go test -run Test_Compress_Zstd
+func Test_Compress_Zstd(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Send(filedata)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, 200, resp.StatusCode, "Status code")
+	utils.AssertEqual(t, "zstd", resp.Header.Get(fiber.HeaderContentEncoding))
+	utils.AssertEqual(t, fiber.HeaderAcceptEncoding, resp.Header.Get(fiber.HeaderVary))
+
+	zr, err := zstd.NewReader(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	defer zr.Close()
+	body, err := ioutil.ReadAll(zr)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, filedata, body)
+}
+
+// go test -run Test_Compress_MinLength
+func Test_Compress_MinLength(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{MinLength: 1024}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Send(filedata[:1023])
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderContentEncoding))
+	utils.AssertEqual(t, fiber.HeaderAcceptEncoding, resp.Header.Get(fiber.HeaderVary))
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 1023, len(body))
+}
+
+// go test -run Test_Compress_MinLength_Negative
+func Test_Compress_MinLength_Negative(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{MinLength: -1}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Send(filedata[:10])
+	})
+	app.Get("/empty", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "gzip", resp.Header.Get(fiber.HeaderContentEncoding))
+
+	// Empty bodies are never compressed
+	req = httptest.NewRequest("GET", "/empty", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderContentEncoding))
+}
+
+// go test -run Test_Compress_Content_Types
+func Test_Compress_Content_Types(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		ContentTypes:        []string{"text/*", fiber.MIMEApplicationJSON},
+		ExcludeContentTypes: []string{"text/csv"},
+	}))
+
+	app.Get("/:type/:subtype", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, c.Params("type")+"/"+c.Params("subtype")+"; charset=utf-8")
+		return c.Send(filedata)
+	})
+
+	for path, encoding := range map[string]string{
+		"/text/html":        "gzip",
+		"/application/json": "gzip",
+		"/text/csv":         "",
+		"/image/png":        "",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+		utils.AssertEqual(t, encoding, resp.Header.Get(fiber.HeaderContentEncoding), path)
+		if encoding == "" {
+			utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderVary), path)
+		}
+	}
+
+	// Images are excluded by default
+	app = fiber.New()
+	app.Use(New())
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Send(filedata)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderContentEncoding))
+}
+
+// go test -run Test_Compress_SendStream
+func Test_Compress_SendStream(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+		// The request is left as it is
+		utils.AssertEqual(t, "zstd, gzip;q=0.9", c.Get(fiber.HeaderAcceptEncoding))
+		return err
+	})
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendStream(bytes.NewReader(filedata), len(filedata))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "zstd, gzip;q=0.9")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, 200, resp.StatusCode, "Status code")
+	utils.AssertEqual(t, "zstd", resp.Header.Get(fiber.HeaderContentEncoding))
+
+	zr, err := zstd.NewReader(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	defer zr.Close()
+	body, err := ioutil.ReadAll(zr)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, filedata, body)
+}
+
+// go test -run Test_Compress_ETag
+func Test_Compress_ETag(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderETag, `"strong"`)
+		return c.Send(filedata)
+	})
+	app.Get("/weak", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderETag, `W/"weak"`)
+		return c.SendStream(bytes.NewReader(filedata), len(filedata))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "gzip", resp.Header.Get(fiber.HeaderContentEncoding))
+	utils.AssertEqual(t, `W/"strong"`, resp.Header.Get(fiber.HeaderETag))
+
+	req = httptest.NewRequest("GET", "/weak", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "gzip", resp.Header.Get(fiber.HeaderContentEncoding))
+	utils.AssertEqual(t, `W/"weak"`, resp.Header.Get(fiber.HeaderETag))
+
+	// The entity tag of an uncompressed response stays strong
+	req = httptest.NewRequest("GET", "/", nil)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, `"strong"`, resp.Header.Get(fiber.HeaderETag))
+}
+
+// go test -run Test_Compress_Already_Encoded
+func Test_Compress_Already_Encoded(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentEncoding, "br")
+		return c.Send(filedata)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "br", resp.Header.Get(fiber.HeaderContentEncoding))
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, filedata, body)
+}
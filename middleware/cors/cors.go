@@ -2,6 +2,7 @@ package cors
 
 import (
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -17,9 +18,16 @@ type Config struct {
 
 	// AllowOrigin defines a list of origins that may access the resource.
 	//
-	// Optional. Default value "*"
+	// Optional. Default value "*", or "" if AllowOriginsFunc is set
 	AllowOrigins string
 
+	// AllowOriginsFunc is called for the origins that are not in
+	// AllowOrigins, the origin is allowed if it returns true. Use it to
+	// match origins with a regular expression or a database lookup.
+	//
+	// Optional. Default: nil
+	AllowOriginsFunc func(origin string) bool
+
 	// AllowMethods defines a list methods allowed when accessing the resource.
 	// This is used in response to a preflight request.
 	//
@@ -35,7 +43,8 @@ type Config struct {
 	// AllowCredentials indicates whether or not the response to the request
 	// can be exposed when the credentials flag is true. When used as part of
 	// a response to a preflight request, this indicates whether or not the
-	// actual request can be made using credentials.
+	// actual request can be made using credentials. It can't be combined
+	// with the wildcard AllowOrigins.
 	//
 	// Optional. Default value false.
 	AllowCredentials bool
@@ -51,6 +60,14 @@ type Config struct {
 	//
 	// Optional. Default value 0.
 	MaxAge int
+
+	// AllowPrivateNetwork allows requests from public websites to private
+	// networks, preflight requests with the Access-Control-Request-Private-Network
+	// header are answered with Access-Control-Allow-Private-Network.
+	// https://wicg.github.io/private-network-access/
+	//
+	// Optional. Default value false.
+	AllowPrivateNetwork bool
 }
 
 // ConfigDefault is the default config
@@ -65,12 +82,22 @@ var ConfigDefault = Config{
 		fiber.MethodDelete,
 		fiber.MethodPatch,
 	}, ","),
-	AllowHeaders:     "",
-	AllowCredentials: false,
-	ExposeHeaders:    "",
-	MaxAge:           0,
+	AllowHeaders:        "",
+	AllowCredentials:    false,
+	ExposeHeaders:       "",
+	MaxAge:              0,
+	AllowPrivateNetwork: false,
 }
 
+const (
+	headerAccessControlAllowPrivateNetwork   = "Access-Control-Allow-Private-Network"
+	headerAccessControlRequestPrivateNetwork = "Access-Control-Request-Private-Network"
+)
+
+// handledKey is the Locals key that holds the route path of the CORS
+// middleware that handles the request, so the most specific one applies its policy
+const handledKey = "cors.handled"
+
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
 	// Set default config
@@ -84,7 +111,7 @@ func New(config ...Config) fiber.Handler {
 		if cfg.Next == nil {
 			cfg.Next = ConfigDefault.Next
 		}
-		if cfg.AllowOrigins == "" {
+		if cfg.AllowOrigins == "" && cfg.AllowOriginsFunc == nil {
 			cfg.AllowOrigins = ConfigDefault.AllowOrigins
 		}
 		if cfg.AllowMethods == "" {
@@ -93,7 +120,20 @@ func New(config ...Config) fiber.Handler {
 	}
 
 	// Convert string to slice
-	allowOrigins := strings.Split(strings.Replace(cfg.AllowOrigins, " ", "", -1), ",")
+	var allowOrigins []string
+	if cfg.AllowOrigins != "" {
+		allowOrigins = strings.Split(strings.Replace(cfg.AllowOrigins, " ", "", -1), ",")
+	}
+
+	// Browsers reject the wildcard with credentials, reflecting any origin
+	// instead would allow every website to make authenticated requests
+	if cfg.AllowCredentials {
+		for _, o := range allowOrigins {
+			if o == "*" {
+				panic("cors: AllowCredentials can't be used with the wildcard AllowOrigins, list the origins or use AllowOriginsFunc")
+			}
+		}
+	}
 
 	// Strip white spaces
 	allowMethods := strings.Replace(cfg.AllowMethods, " ", "", -1)
@@ -110,16 +150,27 @@ func New(config ...Config) fiber.Handler {
 			return c.Next()
 		}
 
+		// The policy of the most specific route applies if a previous CORS
+		// middleware has handled the request, e.g. a global one
+		route := c.Route().Path
+		if handled, ok := c.Locals(handledKey).(string); ok {
+			if len(route) <= len(handled) {
+				return c.Next()
+			}
+			c.Response().Header.Del(fiber.HeaderAccessControlAllowCredentials)
+			c.Response().Header.Del(fiber.HeaderAccessControlExposeHeaders)
+			c.Locals(handledKey, route)
+		} else {
+			c.Locals(handledKey, route)
+			defer c.Locals(handledKey, nil)
+		}
+
 		// Get origin header
 		origin := c.Get(fiber.HeaderOrigin)
 		allowOrigin := ""
 
 		// Check allowed origins
 		for _, o := range allowOrigins {
-			if o == "*" && cfg.AllowCredentials {
-				allowOrigin = origin
-				break
-			}
 			if o == "*" || o == origin {
 				allowOrigin = o
 				break
@@ -129,9 +180,12 @@ func New(config ...Config) fiber.Handler {
 				break
 			}
 		}
+		if allowOrigin == "" && origin != "" && cfg.AllowOriginsFunc != nil && cfg.AllowOriginsFunc(origin) {
+			allowOrigin = origin
+		}
 
-		// Simple request
-		if c.Method() != http.MethodOptions {
+		// Simple request, or an OPTIONS request that is not a preflight
+		if c.Method() != http.MethodOptions || c.Get(fiber.HeaderAccessControlRequestMethod) == "" {
 			c.Vary(fiber.HeaderOrigin)
 			c.Set(fiber.HeaderAccessControlAllowOrigin, allowOrigin)
 
@@ -144,7 +198,12 @@ func New(config ...Config) fiber.Handler {
 			return c.Next()
 		}
 
-		// Preflight request
+		// Preflight request, leave it to a CORS middleware of a more specific
+		// route that matches the request
+		if moreSpecificHandler(c, route) {
+			c.Locals(handledKey, nil)
+			return c.Next()
+		}
 		c.Vary(fiber.HeaderOrigin)
 		c.Vary(fiber.HeaderAccessControlRequestMethod)
 		c.Vary(fiber.HeaderAccessControlRequestHeaders)
@@ -166,6 +225,11 @@ func New(config ...Config) fiber.Handler {
 			}
 		}
 
+		// Set Allow-Private-Network if requested and allowed
+		if cfg.AllowPrivateNetwork && c.Get(headerAccessControlRequestPrivateNetwork) == "true" {
+			c.Set(headerAccessControlAllowPrivateNetwork, "true")
+		}
+
 		// Set MaxAge is set
 		if cfg.MaxAge > 0 {
 			c.Set(fiber.HeaderAccessControlMaxAge, maxAge)
@@ -175,3 +239,26 @@ func New(config ...Config) fiber.Handler {
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 }
+
+// handlerPointer identifies the handlers returned by New
+var handlerPointer uintptr
+
+func init() {
+	handlerPointer = reflect.ValueOf(New()).Pointer()
+}
+
+// moreSpecificHandler returns true if a route after the current one that is
+// more specific than route has a CORS middleware
+func moreSpecificHandler(c *fiber.Ctx, route string) bool {
+	for _, r := range c.NextRoutes() {
+		if len(r.Path) <= len(route) {
+			continue
+		}
+		for _, h := range r.Handlers {
+			if reflect.ValueOf(h).Pointer() == handlerPointer {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package cors
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,6 +27,7 @@ func Test_CORS_Defaults(t *testing.T) {
 	// Test default OPTIONS (preflight) response headers
 	ctx = &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod(fiber.MethodOptions)
+	ctx.Request.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
 	h(ctx)
 
 	utils.AssertEqual(t, "GET,POST,HEAD,PUT,DELETE,PATCH", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowMethods)))
@@ -41,23 +43,208 @@ func Test_CORS_Wildcard(t *testing.T) {
 	handler := app.Handler()
 
 	// OPTIONS (preflight) response headers when AllowOrigins is *
-	app.Use(New(Config{AllowOrigins: "*", AllowCredentials: true, MaxAge: 3600}))
+	app.Use(New(Config{AllowOrigins: "*", MaxAge: 3600}))
 
 	// Make request
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.SetRequestURI("/")
 	ctx.Request.Header.Set(fiber.HeaderOrigin, "localhost")
+	ctx.Request.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
 	ctx.Request.Header.SetMethod(fiber.MethodOptions)
 
 	// Perform request
 	handler(ctx)
 
 	// Check result
-	utils.AssertEqual(t, "localhost", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
-	utils.AssertEqual(t, "true", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+	utils.AssertEqual(t, fiber.StatusNoContent, ctx.Response.StatusCode())
+	utils.AssertEqual(t, "*", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
 	utils.AssertEqual(t, "3600", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlMaxAge)))
 }
 
+// go test -run -v Test_CORS_Wildcard_Credentials
+func Test_CORS_Wildcard_Credentials(t *testing.T) {
+	defer func() {
+		utils.AssertEqual(t, true, recover() != nil)
+	}()
+	New(Config{AllowOrigins: "https://gofiber.io, *", AllowCredentials: true})
+}
+
+// go test -run -v Test_CORS_AllowOriginsFunc
+func Test_CORS_AllowOriginsFunc(t *testing.T) {
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOrigins: "https://gofiber.io",
+		AllowOriginsFunc: func(origin string) bool {
+			return strings.HasSuffix(origin, ".gofiber.net")
+		},
+		AllowCredentials: true,
+	}))
+
+	handler := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	for origin, allowed := range map[string]string{
+		"https://gofiber.io":       "https://gofiber.io",
+		"https://docs.gofiber.net": "https://docs.gofiber.net",
+		"https://evil.com":         "",
+	} {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(fiber.MethodGet)
+		ctx.Request.Header.Set(fiber.HeaderOrigin, origin)
+		handler(ctx)
+		utils.AssertEqual(t, allowed, string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)), origin)
+	}
+}
+
+// go test -run -v Test_CORS_Options_Not_Preflight
+func Test_CORS_Options_Not_Preflight(t *testing.T) {
+	app := fiber.New()
+	app.Use(New())
+	app.Options("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusTeapot)
+	})
+
+	handler := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/")
+	ctx.Request.Header.SetMethod(fiber.MethodOptions)
+	ctx.Request.Header.Set(fiber.HeaderOrigin, "https://gofiber.io")
+	handler(ctx)
+
+	utils.AssertEqual(t, fiber.StatusTeapot, ctx.Response.StatusCode())
+	utils.AssertEqual(t, "*", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowMethods)))
+}
+
+// go test -run -v Test_CORS_Private_Network
+func Test_CORS_Private_Network(t *testing.T) {
+	for _, allow := range []bool{true, false} {
+		app := fiber.New()
+		app.Use(New(Config{AllowPrivateNetwork: allow}))
+
+		handler := app.Handler()
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fiber.MethodOptions)
+		ctx.Request.Header.Set(fiber.HeaderOrigin, "https://gofiber.io")
+		ctx.Request.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
+		ctx.Request.Header.Set(headerAccessControlRequestPrivateNetwork, "true")
+		handler(ctx)
+
+		expected := ""
+		if allow {
+			expected = "true"
+		}
+		utils.AssertEqual(t, expected, string(ctx.Response.Header.Peek(headerAccessControlAllowPrivateNetwork)))
+	}
+}
+
+// go test -run -v Test_CORS_Route_Policy
+func Test_CORS_Route_Policy(t *testing.T) {
+	app := fiber.New()
+	app.Use("/admin", New(Config{AllowOrigins: "https://admin.gofiber.io", AllowCredentials: true}))
+	app.Use(New())
+
+	app.Get("/admin", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	handler := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	ctx.Request.SetRequestURI("/admin")
+	ctx.Request.Header.SetMethod(fiber.MethodGet)
+	ctx.Request.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+	handler(ctx)
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+	utils.AssertEqual(t, "true", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.SetRequestURI("/")
+	ctx.Request.Header.SetMethod(fiber.MethodGet)
+	ctx.Request.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+	handler(ctx)
+	utils.AssertEqual(t, "*", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+}
+
+// go test -run -v Test_CORS_Route_Policy_Registered_Last
+func Test_CORS_Route_Policy_Registered_Last(t *testing.T) {
+	app := fiber.New()
+	app.Use(New(Config{ExposeHeaders: "X-Total"}))
+	app.Use("/admin", New(Config{AllowOrigins: "https://admin.gofiber.io", AllowCredentials: true}))
+
+	app.Get("/admin", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	handler := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// The route policy applies to requests
+	ctx.Request.SetRequestURI("/admin")
+	ctx.Request.Header.SetMethod(fiber.MethodGet)
+	ctx.Request.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+	handler(ctx)
+	utils.AssertEqual(t, fiber.StatusOK, ctx.Response.StatusCode())
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+	utils.AssertEqual(t, "true", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+	utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlExposeHeaders)))
+
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.SetRequestURI("/admin")
+	ctx.Request.Header.SetMethod(fiber.MethodGet)
+	ctx.Request.Header.Set(fiber.HeaderOrigin, "https://admin.gofiber.io")
+	handler(ctx)
+	utils.AssertEqual(t, "https://admin.gofiber.io", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+}
+
+// go test -run -v Test_CORS_Route_Policy_Preflight
+func Test_CORS_Route_Policy_Preflight(t *testing.T) {
+	for _, routeFirst := range []bool{true, false} {
+		app := fiber.New()
+		route := New(Config{AllowOrigins: "https://admin.gofiber.io", AllowMethods: "GET", AllowCredentials: true})
+		if routeFirst {
+			app.Use("/admin", route)
+			app.Use(New())
+		} else {
+			app.Use(New())
+			app.Use("/admin", route)
+		}
+
+		handler := app.Handler()
+		ctx := &fasthttp.RequestCtx{}
+
+		ctx.Request.SetRequestURI("/admin/users")
+		ctx.Request.Header.SetMethod(fiber.MethodOptions)
+		ctx.Request.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodDelete)
+		ctx.Request.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+		handler(ctx)
+		utils.AssertEqual(t, fiber.StatusNoContent, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+		utils.AssertEqual(t, "GET", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowMethods)))
+		utils.AssertEqual(t, "true", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+
+		// Other paths use the global policy
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.SetRequestURI("/users")
+		ctx.Request.Header.SetMethod(fiber.MethodOptions)
+		ctx.Request.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodDelete)
+		ctx.Request.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+		handler(ctx)
+		utils.AssertEqual(t, fiber.StatusNoContent, ctx.Response.StatusCode())
+		utils.AssertEqual(t, "*", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowOrigin)))
+		utils.AssertEqual(t, "", string(ctx.Response.Header.Peek(fiber.HeaderAccessControlAllowCredentials)))
+	}
+}
+
 // go test -run -v Test_CORS_Subdomain
 func Test_CORS_Subdomain(t *testing.T) {
 	// New fiber instance
@@ -3,10 +3,12 @@ package csrf
 import (
 	"crypto/subtle"
 	"errors"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
 	"github.com/gofiber/fiber/v2/utils"
 )
 
@@ -30,18 +32,68 @@ type Config struct {
 
 	// Cookie
 	//
-	// Optional.
+	// Optional. Default: &fiber.Cookie{Name: "_csrf", SameSite: "Lax"}
 	Cookie *fiber.Cookie
 
-	// CookieExpires
+	// CookieExpires overrides the expiration of the cookie, which expires
+	// with the token by default.
 	//
-	// Optional. Default: time.Now().Add(24 * time.Hour)
+	// Optional. Default: time.Now().Add(Expiration)
 	CookieExpires time.Time
 
 	// Context key to store generated CSRF token into context.
 	//
 	// Optional. Default value "csrf".
 	ContextKey string
+
+	// Storage is used to keep track of the issued tokens, a token is only
+	// accepted if it was issued by the server and has not expired. The
+	// default in-memory storage isn't shared by multiple processes, use a
+	// shared Storage or SignKey then.
+	//
+	// Optional. Default: memory.New() without SignKey
+	Storage fiber.Storage
+
+	// Expiration is the duration an issued token stays valid, the cookie
+	// expires with it.
+	//
+	// Optional. Default: 1 * time.Hour
+	Expiration time.Duration
+
+	// SignKey signs the tokens with HMAC-SHA256 for the stateless double
+	// submit pattern without Storage: a token is only accepted if it was
+	// signed with the key and has not expired.
+	//
+	// Optional. Default: nil
+	SignKey []byte
+
+	// SessionKey binds the tokens to a session, a token is only accepted
+	// for the session it was issued for.
+	//
+	// Optional. Default: nil
+	SessionKey func(c *fiber.Ctx) string
+
+	// SingleUseToken replaces the token after every request it was
+	// validated for. With SignKey and without Storage the replaced token
+	// stays valid until it expires.
+	//
+	// Optional. Default: false
+	SingleUseToken bool
+
+	// TrustedOrigins are the origins besides the host of the request that
+	// are accepted in the Origin or Referer header of unsafe HTTPS requests,
+	// such as "https://app.example.com".
+	//
+	// Optional. Default: nil
+	TrustedOrigins []string
+
+	// ErrorHandler is called when a request is rejected, err is one of
+	// ErrTokenNotFound, ErrTokenInvalid, ErrNoReferer or ErrBadOrigin.
+	//
+	// Optional. Default: func(c *fiber.Ctx, err error) error {
+	//   return c.SendStatus(fiber.StatusForbidden)
+	// }
+	ErrorHandler fiber.ErrorHandler
 }
 
 // ConfigDefault is the default config
@@ -49,15 +101,34 @@ var ConfigDefault = Config{
 	Next:        nil,
 	TokenLookup: "header:X-CSRF-Token",
 	ContextKey:  "csrf",
+	Expiration:  1 * time.Hour,
 	Cookie: &fiber.Cookie{
 		Name:     "_csrf",
 		Domain:   "",
 		Path:     "",
 		Secure:   false,
 		HTTPOnly: false,
+		SameSite: "Lax",
+	},
+	ErrorHandler: func(c *fiber.Ctx, err error) error {
+		return c.SendStatus(fiber.StatusForbidden)
 	},
 }
 
+var (
+	// ErrTokenNotFound is returned when the request has no token
+	ErrTokenNotFound = errors.New("csrf: token not found")
+	// ErrTokenInvalid is returned when the token was not issued, has
+	// expired, belongs to another session or doesn't match the cookie
+	ErrTokenInvalid = errors.New("csrf: token invalid")
+	// ErrNoReferer is returned when an HTTPS request has neither an Origin
+	// nor a Referer header
+	ErrNoReferer = errors.New("csrf: origin and referer missing")
+	// ErrBadOrigin is returned when the Origin or Referer of an HTTPS
+	// request is not trusted
+	ErrBadOrigin = errors.New("csrf: origin not trusted")
+)
+
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
 	// Set default config
@@ -77,24 +148,49 @@ func New(config ...Config) fiber.Handler {
 		if cfg.Cookie == nil {
 			cfg.Cookie = ConfigDefault.Cookie
 		}
-		if cfg.CookieExpires.IsZero() {
-			cfg.CookieExpires = ConfigDefault.CookieExpires
+		// Don't modify the cookie of the caller
+		cookie := *cfg.Cookie
+		if cookie.Name == "" {
+			cookie.Name = ConfigDefault.Cookie.Name
+		}
+		if cookie.SameSite == "" {
+			cookie.SameSite = ConfigDefault.Cookie.SameSite
+		}
+		cfg.Cookie = &cookie
+		if cfg.Expiration <= 0 {
+			cfg.Expiration = ConfigDefault.Expiration
 		}
+		if cfg.ErrorHandler == nil {
+			cfg.ErrorHandler = ConfigDefault.ErrorHandler
+		}
+	}
+	if cfg.Storage == nil && len(cfg.SignKey) == 0 {
+		cfg.Storage = memory.New()
 	}
 
 	// Generate the correct extractor to get the token from the correct location
 	selectors := strings.Split(cfg.TokenLookup, ":")
+	if len(selectors) != 2 || selectors[1] == "" {
+		panic("csrf: TokenLookup must be in the form of <header|query|param|form>:<name>")
+	}
 
-	// By default we extract from a header
-	extractor := csrfFromHeader(selectors[1])
-
+	var extractor func(c *fiber.Ctx) (string, error)
 	switch selectors[0] {
+	case "header":
+		extractor = csrfFromHeader(selectors[1])
 	case "form":
 		extractor = csrfFromForm(selectors[1])
 	case "query":
 		extractor = csrfFromQuery(selectors[1])
 	case "param":
 		extractor = csrfFromParam(selectors[1])
+	default:
+		panic("csrf: TokenLookup must be in the form of <header|query|param|form>:<name>")
+	}
+
+	trustedOrigins := make([]string, len(cfg.TrustedOrigins))
+	for i, origin := range cfg.TrustedOrigins {
+		trustedOrigins[i] = utils.ToLower(strings.TrimSuffix(origin, "/"))
 	}
 
 	// Return new handler
@@ -104,47 +200,69 @@ func New(config ...Config) fiber.Handler {
 			return c.Next()
 		}
 
-		// Declare empty token and try to get previous generated CSRF from cookie
-		token, key := "", c.Cookies(cfg.Cookie.Name)
-
-		// Check if the cookie had a CSRF token
-		if key == "" {
-			// Create a new CSRF token
-			token = utils.UUID()
-		} else {
-			// Use the server generated token previously to compare
-			// To the extracted token later on
-			token = key
+		// Get the previous generated CSRF token from the cookie
+		key := c.Cookies(cfg.Cookie.Name)
+		session := ""
+		if cfg.SessionKey != nil {
+			session = cfg.SessionKey(c)
+		}
+		valid, err := cfg.validToken(key, session)
+		if err != nil {
+			return err
 		}
 
-		// Verify CSRF token on POST requests
-		if c.Method() == fiber.MethodPost {
+		// Verify CSRF token on unsafe requests
+		if !isSafeMethod(c.Method()) {
+			if c.Protocol() == "https" {
+				if err := checkOrigin(c, trustedOrigins); err != nil {
+					return cfg.ErrorHandler(c, err)
+				}
+			}
 			// Extract token from client request i.e. header, query, param or form
 			csrf, err := extractor(c)
-			if err != nil {
+			if err != nil || key == "" {
 				// We have a problem extracting the csrf token
-				return c.SendStatus(fiber.StatusForbidden)
+				return cfg.ErrorHandler(c, ErrTokenNotFound)
 			}
 			// Some magic to compare both cookie and client csrf token
-			if subtle.ConstantTimeCompare(utils.GetBytes(token), utils.GetBytes(csrf)) != 1 {
+			if !valid || subtle.ConstantTimeCompare(utils.GetBytes(key), utils.GetBytes(csrf)) != 1 {
 				// Comparison failed, return forbidden
-				return c.SendStatus(fiber.StatusForbidden)
+				return cfg.ErrorHandler(c, ErrTokenInvalid)
+			}
+			if cfg.SingleUseToken {
+				if cfg.Storage != nil {
+					if err := cfg.Storage.Delete(keyPrefix + key); err != nil {
+						return err
+					}
+				}
+				valid = false
 			}
 		}
 
-		// Create new cookie to send new CSRF token
-		cookie := &fiber.Cookie{
-			Name:     cfg.Cookie.Name,
-			Value:    token,
-			Domain:   cfg.Cookie.Domain,
-			Path:     cfg.Cookie.Path,
-			Expires:  cfg.CookieExpires,
-			Secure:   cfg.Cookie.Secure,
-			HTTPOnly: cfg.Cookie.HTTPOnly,
-		}
+		// Create a new CSRF token if there is no valid one
+		token := key
+		if !valid {
+			if token, err = cfg.newToken(session); err != nil {
+				return err
+			}
 
-		// Set cookie to response
-		c.Cookie(cookie)
+			expires := cfg.CookieExpires
+			if expires.IsZero() {
+				expires = time.Now().Add(cfg.Expiration)
+			}
+
+			// Set cookie to response
+			c.Cookie(&fiber.Cookie{
+				Name:     cfg.Cookie.Name,
+				Value:    token,
+				Domain:   cfg.Cookie.Domain,
+				Path:     cfg.Cookie.Path,
+				Expires:  expires,
+				Secure:   cfg.Cookie.Secure,
+				HTTPOnly: cfg.Cookie.HTTPOnly,
+				SameSite: cfg.Cookie.SameSite,
+			})
+		}
 
 		// Store token in context
 		c.Locals(cfg.ContextKey, token)
@@ -158,6 +276,44 @@ func New(config ...Config) fiber.Handler {
 	}
 }
 
+// isSafeMethod returns true for the methods that don't change state
+// https://datatracker.ietf.org/doc/html/rfc7231#section-4.2.1
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// checkOrigin verifies that the Origin, or the Referer if there is no
+// Origin, is the host of the request or a trusted origin
+func checkOrigin(c *fiber.Ctx, trustedOrigins []string) error {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" || origin == "null" {
+		referer := c.Get(fiber.HeaderReferer)
+		if referer == "" {
+			return ErrNoReferer
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return ErrBadOrigin
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	origin = utils.ToLower(origin)
+
+	if origin == "https://"+utils.ToLower(c.Hostname()) {
+		return nil
+	}
+	for _, trusted := range trustedOrigins {
+		if origin == trusted {
+			return nil
+		}
+	}
+	return ErrBadOrigin
+}
+
 // csrfFromHeader returns a function that extracts token from the request header.
 func csrfFromHeader(param string) func(c *fiber.Ctx) (string, error) {
 	return func(c *fiber.Ctx) (string, error) {
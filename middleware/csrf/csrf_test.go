@@ -1,10 +1,13 @@
 package csrf
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
 	"github.com/gofiber/fiber/v2/utils"
 	"github.com/valyala/fasthttp"
 )
@@ -41,8 +44,22 @@ func Test_CSRF(t *testing.T) {
 	h(ctx)
 	utils.AssertEqual(t, 403, ctx.Response.StatusCode())
 
+	// Token that matches the cookie but was not issued by the server
+	forged := utils.UUID()
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+forged)
+	ctx.Request.Header.Set("X-CSRF-Token", forged)
+	h(ctx)
+	utils.AssertEqual(t, 403, ctx.Response.StatusCode())
+
 	// Valid CSRF token
-	token := utils.UUID()
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("GET")
+	h(ctx)
+	token := issuedToken(t, ctx)
 	ctx.Request.Reset()
 	ctx.Response.Reset()
 	ctx.Request.Header.SetMethod("POST")
@@ -51,3 +68,295 @@ func Test_CSRF(t *testing.T) {
 	h(ctx)
 	utils.AssertEqual(t, 200, ctx.Response.StatusCode())
 }
+
+// issuedToken returns the token of the csrf cookie of the response
+func issuedToken(t *testing.T, ctx *fasthttp.RequestCtx) string {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("_csrf")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(cookie))
+	return string(cookie.Value())
+}
+
+func Test_CSRF_Storage(t *testing.T) {
+	storage := memory.New()
+	defer storage.Close()
+
+	app := fiber.New()
+
+	app.Use(New(Config{Storage: storage}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token
+	ctx.Request.Header.SetMethod("GET")
+	h(ctx)
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("_csrf")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(cookie))
+	token := string(cookie.Value())
+
+	// Token that was not issued by the server
+	forged := utils.UUID()
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+forged)
+	ctx.Request.Header.Set("X-CSRF-Token", forged)
+	h(ctx)
+	utils.AssertEqual(t, 403, ctx.Response.StatusCode())
+
+	// Issued CSRF token
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+token)
+	ctx.Request.Header.Set("X-CSRF-Token", token)
+	h(ctx)
+	utils.AssertEqual(t, 200, ctx.Response.StatusCode())
+
+	// Expired or revoked token
+	utils.AssertEqual(t, nil, storage.Delete(keyPrefix+token))
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+token)
+	ctx.Request.Header.Set("X-CSRF-Token", token)
+	h(ctx)
+	utils.AssertEqual(t, 403, ctx.Response.StatusCode())
+}
+
+// go test -run Test_CSRF_Unsafe_Methods
+func Test_CSRF_Unsafe_Methods(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.All("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "TRACE"} {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(method)
+		h(ctx)
+		utils.AssertEqual(t, 200, ctx.Response.StatusCode(), method)
+	}
+
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(method)
+		h(ctx)
+		utils.AssertEqual(t, 403, ctx.Response.StatusCode(), method)
+	}
+}
+
+// go test -run Test_CSRF_Origin
+func Test_CSRF_Origin(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		TrustedOrigins: []string{"https://app.example.com/"},
+	}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	h(ctx)
+	token := issuedToken(t, ctx)
+
+	request := func(header, value string) int {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.SetHost("example.com")
+		ctx.Request.Header.Set(fiber.HeaderXForwardedProto, "https")
+		ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+token)
+		ctx.Request.Header.Set("X-CSRF-Token", token)
+		if header != "" {
+			ctx.Request.Header.Set(header, value)
+		}
+		h(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	utils.AssertEqual(t, 403, request("", ""))
+	utils.AssertEqual(t, 200, request(fiber.HeaderOrigin, "https://example.com"))
+	utils.AssertEqual(t, 200, request(fiber.HeaderOrigin, "https://app.example.com"))
+	utils.AssertEqual(t, 403, request(fiber.HeaderOrigin, "http://example.com"))
+	utils.AssertEqual(t, 403, request(fiber.HeaderOrigin, "https://evil.com"))
+	utils.AssertEqual(t, 200, request(fiber.HeaderReferer, "https://example.com/form"))
+	utils.AssertEqual(t, 403, request(fiber.HeaderReferer, "https://evil.com/form"))
+}
+
+// go test -run Test_CSRF_SignKey
+func Test_CSRF_SignKey(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		SignKey: []byte("secret"),
+		SessionKey: func(c *fiber.Ctx) string {
+			return c.Get("X-Session")
+		},
+	}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("X-Session", "john")
+	h(ctx)
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("_csrf")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(cookie))
+	utils.AssertEqual(t, fasthttp.CookieSameSiteLaxMode, cookie.SameSite())
+	token := string(cookie.Value())
+
+	request := func(token, session string) int {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+token)
+		ctx.Request.Header.Set("X-CSRF-Token", token)
+		ctx.Request.Header.Set("X-Session", session)
+		h(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	utils.AssertEqual(t, 200, request(token, "john"))
+	// Token of another session
+	utils.AssertEqual(t, 403, request(token, "doe"))
+	// Forged token
+	utils.AssertEqual(t, 403, request(utils.UUID(), "john"))
+	// Tampered expiration
+	parts := strings.Split(token, ".")
+	utils.AssertEqual(t, 403, request(parts[0]+".9999999999."+parts[2], "john"))
+
+	// Expired token
+	cfg := Config{SignKey: []byte("secret"), Expiration: -time.Second}
+	expired, err := cfg.newToken("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 403, request(expired, "john"))
+}
+
+// go test -run Test_CSRF_Storage_Session
+func Test_CSRF_Storage_Session(t *testing.T) {
+	storage := memory.New()
+	defer storage.Close()
+
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Storage:        storage,
+		SingleUseToken: true,
+		SessionKey: func(c *fiber.Ctx) string {
+			return c.Get("X-Session")
+		},
+	}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("X-Session", "john")
+	h(ctx)
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("_csrf")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(cookie))
+	token := string(cookie.Value())
+
+	request := func(session string) int {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf="+token)
+		ctx.Request.Header.Set("X-CSRF-Token", token)
+		ctx.Request.Header.Set("X-Session", session)
+		h(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	utils.AssertEqual(t, 403, request("doe"))
+	utils.AssertEqual(t, 200, request("john"))
+
+	// The token is replaced after it was used
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(cookie))
+	utils.AssertEqual(t, true, string(cookie.Value()) != token)
+	utils.AssertEqual(t, 403, request("john"))
+}
+
+// go test -run Test_CSRF_ErrorHandler
+func Test_CSRF_ErrorHandler(t *testing.T) {
+	app := fiber.New()
+
+	var errs []error
+	app.Use(New(Config{
+		TokenLookup: "form:_csrf",
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			errs = append(errs, err)
+			return c.SendStatus(fiber.StatusTeapot)
+		},
+	}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	ctx.Request.Header.SetMethod("POST")
+	h(ctx)
+	utils.AssertEqual(t, 418, ctx.Response.StatusCode())
+
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.SetContentType(fiber.MIMEApplicationForm)
+	ctx.Request.Header.Set(fiber.HeaderCookie, "_csrf=token")
+	ctx.Request.SetBodyString("_csrf=other")
+	h(ctx)
+	utils.AssertEqual(t, 418, ctx.Response.StatusCode())
+
+	utils.AssertEqual(t, true, errors.Is(errs[0], ErrTokenNotFound))
+	utils.AssertEqual(t, true, errors.Is(errs[1], ErrTokenInvalid))
+}
+
+// go test -run Test_CSRF_TokenLookup
+func Test_CSRF_TokenLookup(t *testing.T) {
+	for _, lookup := range []string{"header", "header:", "cookie:_csrf", "header:a:b"} {
+		func() {
+			defer func() {
+				utils.AssertEqual(t, true, recover() != nil, lookup)
+			}()
+			New(Config{TokenLookup: lookup})
+		}()
+	}
+}
@@ -0,0 +1,79 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// keyPrefix separates the tokens from the keys of other middlewares
+// sharing the storage
+const keyPrefix = "csrf:"
+
+// newToken creates a token for the session and signs it with the SignKey,
+// or stores it in the Storage
+func (cfg *Config) newToken(session string) (string, error) {
+	if len(cfg.SignKey) > 0 {
+		random := make([]byte, 18)
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		// <random>.<expiration>.<signature>
+		payload := base64.RawURLEncoding.EncodeToString(random) + "." +
+			strconv.FormatInt(time.Now().Add(cfg.Expiration).Unix(), 10)
+		return payload + "." + cfg.sign(payload, session), nil
+	}
+
+	token := utils.UUID()
+	// The value is prefixed, so a token without session is not empty
+	if err := cfg.Storage.Set(keyPrefix+token, append([]byte{1}, session...), cfg.Expiration); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// validToken returns true if the token was issued for the session and has
+// not expired
+func (cfg *Config) validToken(token, session string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	if len(cfg.SignKey) > 0 {
+		i := strings.LastIndexByte(token, '.')
+		if i < 0 {
+			return false, nil
+		}
+		payload, signature := token[:i], token[i+1:]
+		if subtle.ConstantTimeCompare([]byte(signature), []byte(cfg.sign(payload, session))) != 1 {
+			return false, nil
+		}
+		expiration, err := strconv.ParseInt(payload[strings.LastIndexByte(payload, '.')+1:], 10, 64)
+		if err != nil {
+			return false, nil
+		}
+		return time.Now().Unix() < expiration, nil
+	}
+
+	raw, err := cfg.Storage.Get(keyPrefix + token)
+	if err != nil || len(raw) == 0 {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(raw[1:], []byte(session)) == 1, nil
+}
+
+// sign returns the signature of the token payload for the session
+func (cfg *Config) sign(payload, session string) string {
+	mac := hmac.New(sha256.New, cfg.SignKey)
+	_, _ = mac.Write([]byte(payload))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package decompress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// BodyLimit is the maximum size in bytes of the decompressed body,
+	// larger bodies are rejected with 413 Request Entity Too Large.
+	//
+	// Optional. Default: the BodyLimit of the app
+	BodyLimit int
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:      nil,
+	BodyLimit: 0,
+}
+
+// supportedEncodings is sent in the Accept-Encoding header of a 415 response
+// https://datatracker.ietf.org/doc/html/rfc7694#section-3
+const supportedEncodings = "gzip, deflate, br, zstd"
+
+// New creates a new middleware handler
+func New(config ...Config) fiber.Handler {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Next == nil {
+			cfg.Next = ConfigDefault.Next
+		}
+	}
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		header := c.Get(fiber.HeaderContentEncoding)
+		if header == "" {
+			return c.Next()
+		}
+
+		// The encodings are listed in the order they were applied
+		var encodings []string
+		for _, encoding := range strings.Split(header, ",") {
+			encoding = utils.ToLower(utils.Trim(encoding, ' '))
+			if encoding == "" || encoding == "identity" {
+				continue
+			}
+			if !supported(encoding) {
+				c.Set(fiber.HeaderAcceptEncoding, supportedEncodings)
+				return fiber.ErrUnsupportedMediaType
+			}
+			encodings = append(encodings, encoding)
+		}
+
+		limit := cfg.BodyLimit
+		if limit <= 0 {
+			limit = c.App().Config().BodyLimit
+		}
+
+		body, err := decompress(c.BodyStream(), encodings, limit)
+		if err != nil {
+			return err
+		}
+
+		// Handlers see the request as if it was sent uncompressed
+		c.Request().SetBody(body)
+		c.Request().Header.Del(fiber.HeaderContentEncoding)
+		c.Request().Header.SetContentLength(len(body))
+
+		return c.Next()
+	}
+}
+
+func supported(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// decompress decodes r with the encodings in reverse order and returns
+// ErrRequestEntityTooLarge if the result is larger than limit
+func decompress(r io.Reader, encodings []string, limit int) ([]byte, error) {
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = newDeflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		case "zstd":
+			// Limit the memory of the decoder, allowing the 8 MB window
+			// encoders use for data of unknown size
+			maxMemory := uint64(limit) + 1
+			if maxMemory < 8<<20 {
+				maxMemory = 8 << 20
+			}
+			var d *zstd.Decoder
+			d, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxMemory))
+			if err == nil {
+				defer d.Close()
+				r = d
+			}
+		}
+		if err != nil {
+			return nil, decodeError(err)
+		}
+	}
+
+	// Read one byte past the limit to detect an oversized body
+	body, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	if len(body) > limit {
+		return nil, fiber.ErrRequestEntityTooLarge
+	}
+	return body, nil
+}
+
+// decodeError returns ErrRequestEntityTooLarge if the compressed body exceeds
+// the BodyLimit of the app or the decoder its memory, otherwise ErrBadRequest
+func decodeError(err error) error {
+	if errors.Is(err, fiber.ErrRequestEntityTooLarge) ||
+		errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return fiber.ErrRequestEntityTooLarge
+	}
+	return fiber.ErrBadRequest
+}
+
+// newDeflateReader reads the zlib format of the deflate coding, or raw
+// deflate data which some clients send instead
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// A zlib header uses the deflate method and is a multiple of 31
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
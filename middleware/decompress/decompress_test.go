@@ -0,0 +1,174 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+var payload = []byte(`{"device":"sensor-1","temperature":21.5}`)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		var err error
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		utils.AssertEqual(t, nil, err)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		w, err = zstd.NewWriter(&buf)
+		utils.AssertEqual(t, nil, err)
+	}
+	_, err := w.Write(data)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, w.Close())
+	return buf.Bytes()
+}
+
+func newApp(config ...Config) *fiber.App {
+	app := fiber.New()
+	app.Use(New(config...))
+	app.Post("/", func(c *fiber.Ctx) error {
+		var data struct {
+			Device      string  `json:"device"`
+			Temperature float64 `json:"temperature"`
+		}
+		if err := c.BodyParser(&data); err != nil {
+			return err
+		}
+		return c.SendString(data.Device + c.Get(fiber.HeaderContentEncoding))
+	})
+	return app
+}
+
+func request(app *fiber.App, encoding string, body []byte) (int, string, error) {
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderContentEncoding, encoding)
+	resp, err := app.Test(req)
+	if err != nil {
+		return 0, "", err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(b), err
+}
+
+// go test -run Test_Decompress
+func Test_Decompress(t *testing.T) {
+	app := newApp()
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		status, body, err := request(app, encoding, compress(t, encoding, payload))
+		utils.AssertEqual(t, nil, err, encoding)
+		utils.AssertEqual(t, 200, status, encoding)
+		utils.AssertEqual(t, "sensor-1", body, encoding)
+	}
+
+	// Raw deflate data without zlib header
+	status, body, err := request(app, "deflate", compress(t, "raw-deflate", payload))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, status)
+	utils.AssertEqual(t, "sensor-1", body)
+
+	// Multiple encodings are decoded in reverse order
+	status, body, err = request(app, "gzip, br", compress(t, "br", compress(t, "gzip", payload)))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, status)
+	utils.AssertEqual(t, "sensor-1", body)
+
+	// Uncompressed body
+	status, body, err = request(app, "", payload)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, status)
+	utils.AssertEqual(t, "sensor-1", body)
+}
+
+// go test -run Test_Decompress_Unsupported
+func Test_Decompress_Unsupported(t *testing.T) {
+	app := newApp()
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set(fiber.HeaderContentEncoding, "compress")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusUnsupportedMediaType, resp.StatusCode)
+	utils.AssertEqual(t, "gzip, deflate, br, zstd", resp.Header.Get(fiber.HeaderAcceptEncoding))
+}
+
+// go test -run Test_Decompress_Invalid
+func Test_Decompress_Invalid(t *testing.T) {
+	app := newApp()
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		status, _, err := request(app, encoding, payload)
+		utils.AssertEqual(t, nil, err, encoding)
+		utils.AssertEqual(t, fiber.StatusBadRequest, status, encoding)
+	}
+}
+
+// go test -run Test_Decompress_BodyLimit
+func Test_Decompress_BodyLimit(t *testing.T) {
+	app := newApp(Config{BodyLimit: 4096})
+
+	// A small compressed body that expands beyond the limit
+	bomb := make([]byte, 1<<20)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		compressed := compress(t, encoding, bomb)
+		utils.AssertEqual(t, true, len(compressed) < 4096, encoding)
+
+		status, _, err := request(app, encoding, compressed)
+		utils.AssertEqual(t, nil, err, encoding)
+		utils.AssertEqual(t, fiber.StatusRequestEntityTooLarge, status, encoding)
+	}
+
+	// The BodyLimit of the app is used by default
+	app = fiber.New(fiber.Config{BodyLimit: 4096})
+	app.Use(New())
+	app.Post("/", func(c *fiber.Ctx) error {
+		return nil
+	})
+	status, _, err := request(app, "gzip", compress(t, "gzip", bomb))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusRequestEntityTooLarge, status)
+}
+
+// go test -run Test_Decompress_Compressed_BodyLimit
+func Test_Decompress_Compressed_BodyLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{BodyLimit: 4096, StreamRequestBody: true})
+	app.Use(New(Config{BodyLimit: 1 << 20}))
+	app.Post("/", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	// Random data doesn't compress, the compressed body exceeds the limit
+	data := make([]byte, 8192)
+	_, err := rand.Read(data)
+	utils.AssertEqual(t, nil, err)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		compressed := compress(t, encoding, data)
+		utils.AssertEqual(t, true, len(compressed) > 4096, encoding)
+
+		status, _, err := request(app, encoding, compressed)
+		utils.AssertEqual(t, nil, err, encoding)
+		utils.AssertEqual(t, fiber.StatusRequestEntityTooLarge, status, encoding)
+	}
+}
@@ -0,0 +1,131 @@
+package encryptcookie
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// Array of cookie keys that should not be encrypted.
+	//
+	// Optional. Default: []
+	Except []string
+
+	// Base64 encoded unique key to encode & decode cookies.
+	// Must be 32 bytes long, use encryptcookie.GenerateKey() to generate one.
+	// New panics if the key is invalid.
+	//
+	// Required.
+	Key string
+
+	// Custom function to encrypt cookies, the value should be bound to the
+	// cookie name so it can't be moved to another cookie.
+	//
+	// Optional. Default: EncryptCookie
+	Encryptor func(name, decryptedString, key string) (string, error)
+
+	// Custom function to decrypt cookies.
+	//
+	// Optional. Default: DecryptCookie
+	Decryptor func(name, encryptedString, key string) (string, error)
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:      nil,
+	Except:    []string{},
+	Key:       "",
+	Encryptor: EncryptCookie,
+	Decryptor: DecryptCookie,
+}
+
+// New creates a new middleware handler
+func New(config ...Config) fiber.Handler {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Next == nil {
+			cfg.Next = ConfigDefault.Next
+		}
+		if cfg.Except == nil {
+			cfg.Except = ConfigDefault.Except
+		}
+		if cfg.Encryptor == nil {
+			cfg.Encryptor = ConfigDefault.Encryptor
+		}
+		if cfg.Decryptor == nil {
+			cfg.Decryptor = ConfigDefault.Decryptor
+		}
+	}
+
+	if cfg.Key == "" {
+		panic("encryptcookie: Key is required, use encryptcookie.GenerateKey() to generate one")
+	}
+	// Fail on startup instead of on every request
+	if err := validKey(cfg.Key); err != nil {
+		panic("encryptcookie: Key must be a base64 encoded 32 byte key, use encryptcookie.GenerateKey() to generate one")
+	}
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Decrypt request cookies, cookies that fail to decrypt are dropped
+		var keys, values []string
+		c.Request().Header.VisitAllCookie(func(key, value []byte) {
+			keys = append(keys, string(key))
+			values = append(values, string(value))
+		})
+		for i, key := range keys {
+			if isDisabled(key, cfg.Except) {
+				continue
+			}
+			decrypted, err := cfg.Decryptor(key, values[i], cfg.Key)
+			if err != nil {
+				c.Request().Header.DelCookie(key)
+			} else {
+				c.Request().Header.SetCookie(key, decrypted)
+			}
+		}
+
+		// Continue stack
+		err := c.Next()
+
+		// Encrypt response cookies
+		var cookies [][]byte
+		c.Response().Header.VisitAllCookie(func(key, value []byte) {
+			if !isDisabled(string(key), cfg.Except) {
+				cookies = append(cookies, append([]byte(nil), value...))
+			}
+		})
+		for _, raw := range cookies {
+			cookie := fasthttp.AcquireCookie()
+			if cookie.ParseBytes(raw) == nil {
+				encrypted, encErr := cfg.Encryptor(string(cookie.Key()), string(cookie.Value()), cfg.Key)
+				if encErr != nil {
+					fasthttp.ReleaseCookie(cookie)
+					return encErr
+				}
+				cookie.SetValue(encrypted)
+				c.Response().Header.SetCookie(cookie)
+			}
+			fasthttp.ReleaseCookie(cookie)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,118 @@
+package encryptcookie
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+var testKey = GenerateKey()
+
+// go test -run Test_EncryptCookie
+func Test_EncryptCookie(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Key:    testKey,
+		Except: []string{"plain"},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("value=" + c.Cookies("test") + ", plain=" + c.Cookies("plain"))
+	})
+	app.Post("/", func(c *fiber.Ctx) error {
+		c.Cookie(&fiber.Cookie{Name: "test", Value: "SomeThing"})
+		c.Cookie(&fiber.Cookie{Name: "plain", Value: "visible"})
+		return nil
+	})
+
+	h := app.Handler()
+
+	// Outgoing cookies are encrypted, excluded ones are untouched
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	h(ctx)
+	utils.AssertEqual(t, 200, ctx.Response.StatusCode())
+
+	encrypted := fasthttp.AcquireCookie()
+	encrypted.SetKey("test")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(encrypted))
+	utils.AssertEqual(t, true, string(encrypted.Value()) != "SomeThing")
+
+	plain := fasthttp.AcquireCookie()
+	plain.SetKey("plain")
+	utils.AssertEqual(t, true, ctx.Response.Header.Cookie(plain))
+	utils.AssertEqual(t, "visible", string(plain.Value()))
+
+	// Incoming cookies are decrypted
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.SetCookie("test", string(encrypted.Value()))
+	ctx.Request.Header.SetCookie("plain", "visible")
+	h(ctx)
+	utils.AssertEqual(t, 200, ctx.Response.StatusCode())
+	utils.AssertEqual(t, "value=SomeThing, plain=visible", string(ctx.Response.Body()))
+
+	// Cookies that fail to decrypt are dropped
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.SetCookie("test", "Invalid")
+	h(ctx)
+	utils.AssertEqual(t, "value=, plain=", string(ctx.Response.Body()))
+}
+
+// go test -run Test_EncryptCookie_Next
+func Test_EncryptCookie_Next(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Key: testKey,
+		Next: func(_ *fiber.Ctx) bool {
+			return true
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Cookie(&fiber.Cookie{Name: "test", Value: "SomeThing"})
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "test=SomeThing; path=/; SameSite=Lax", resp.Header.Get(fiber.HeaderSetCookie))
+}
+
+// go test -run Test_EncryptCookie_Panic
+func Test_EncryptCookie_Panic(t *testing.T) {
+	for _, key := range []string{"", "not base64", "c2hvcnQ="} {
+		func() {
+			defer func() {
+				utils.AssertEqual(t, true, recover() != nil, key)
+			}()
+			New(Config{Key: key})
+		}()
+	}
+}
+
+// go test -run Test_EncryptCookie_Roundtrip
+func Test_EncryptCookie_Roundtrip(t *testing.T) {
+	encrypted, err := EncryptCookie("test", "value", testKey)
+	utils.AssertEqual(t, nil, err)
+
+	decrypted, err := DecryptCookie("test", encrypted, testKey)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "value", decrypted)
+
+	_, err = DecryptCookie("test", encrypted, GenerateKey())
+	utils.AssertEqual(t, ErrInvalidCookie, err)
+
+	// The value is bound to the cookie name
+	_, err = DecryptCookie("other", encrypted, testKey)
+	utils.AssertEqual(t, ErrInvalidCookie, err)
+
+	_, err = EncryptCookie("test", "value", "not a key")
+	utils.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,93 @@
+package encryptcookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidCookie is returned when a cookie value cannot be decrypted
+var ErrInvalidCookie = errors.New("encryptcookie: invalid encrypted value")
+
+// EncryptCookie encrypts a cookie value with AES-GCM using the base64 encoded
+// key, the cookie name is authenticated as additional data so the value only
+// decrypts for the same cookie
+func EncryptCookie(name, value, key string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptCookie decrypts a cookie value encrypted by EncryptCookie for the
+// same cookie name
+func DecryptCookie(name, value, key string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", ErrInvalidCookie
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	return string(plaintext), nil
+}
+
+// GenerateKey generates a random, base64 encoded 32 byte key
+func GenerateKey() string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// validKey returns an error if key is not a base64 encoded 32 byte key
+func validKey(key string) error {
+	keyDecoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return err
+	}
+	if len(keyDecoded) != 32 {
+		return aes.KeySizeError(len(keyDecoded))
+	}
+	return nil
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	keyDecoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(keyDecoded)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isDisabled checks if the cookie key is part of the exclusion list
+func isDisabled(key string, except []string) bool {
+	for _, k := range except {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
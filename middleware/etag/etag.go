@@ -0,0 +1,152 @@
+package etag
+
+import (
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/internal/bytebufferpool"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// Weak indicates that a weak validator is used. Weak etags are easy
+	// to generate, but are far less useful for comparisons. Strong
+	// validators are ideal for comparisons but can be very difficult
+	// to generate efficiently.
+	//
+	// Optional. Default: false
+	Weak bool
+
+	// Hash returns the hash used to generate the ETag from the body.
+	//
+	// Optional. Default: func() hash.Hash {
+	//   return crc32.New(crc32.MakeTable(0xD5828281))
+	// }
+	Hash func() hash.Hash
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next: nil,
+	Weak: false,
+	Hash: func() hash.Hash {
+		return crc32.New(crc32q)
+	},
+}
+
+var crc32q = crc32.MakeTable(0xD5828281)
+
+// New creates a new middleware handler
+func New(config ...Config) fiber.Handler {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Next == nil {
+			cfg.Next = ConfigDefault.Next
+		}
+		if cfg.Hash == nil {
+			cfg.Hash = ConfigDefault.Hash
+		}
+	}
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Continue stack, the ETag is generated from the final response
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+
+		// Don't generate ETags for invalid responses or responses without a body
+		if resp.StatusCode() < fiber.StatusOK || resp.StatusCode() >= fiber.StatusMultipleChoices || resp.StatusCode() == fiber.StatusNoContent {
+			return nil
+		}
+		// Don't buffer streamed responses to hash them
+		if resp.IsBodyStream() {
+			return nil
+		}
+
+		// Use the ETag set by the handler, otherwise generate one from the body
+		etag := string(resp.Header.Peek(fiber.HeaderETag))
+		if etag == "" {
+			body := resp.Body()
+			// Skips ETag if no response body is present
+			if len(body) <= 0 {
+				return nil
+			}
+			etag = generate(cfg, body)
+			c.Set(fiber.HeaderETag, etag)
+		}
+
+		// Only safe methods can be answered with 304 Not Modified
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return nil
+		}
+
+		if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" && matches(noneMatch, etag) {
+			c.Status(fiber.StatusNotModified)
+			resp.ResetBody()
+		}
+		return nil
+	}
+}
+
+// generate creates the ETag of the body, the length is included to make
+// collisions of the hash less likely
+func generate(cfg Config, body []byte) string {
+	h := cfg.Hash()
+	_, _ = h.Write(body)
+
+	bb := bytebufferpool.Get()
+	defer bytebufferpool.Put(bb)
+
+	if cfg.Weak {
+		_, _ = bb.WriteString("W/")
+	}
+	_ = bb.WriteByte('"')
+	_, _ = bb.WriteString(strconv.Itoa(len(body)))
+	_ = bb.WriteByte('-')
+	sum := h.Sum(nil)
+	dst := make([]byte, hex.EncodedLen(len(sum)))
+	hex.Encode(dst, sum)
+	_, _ = bb.Write(dst)
+	_ = bb.WriteByte('"')
+	return bb.String()
+}
+
+// matches reports whether the If-None-Match list contains the etag using the
+// weak comparison, "*" matches any etag
+// https://tools.ietf.org/html/rfc7232#section-3.2
+func matches(noneMatch, etag string) bool {
+	if utils.Trim(noneMatch, ' ') == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(noneMatch, ",") {
+		if strings.TrimPrefix(utils.Trim(tag, ' '), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package etag
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"hash"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_ETag
+func Test_ETag(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get(fiber.HeaderETag)
+	utils.AssertEqual(t, `"13-`, etag[:4])
+	utils.AssertEqual(t, false, etag[0] == 'W')
+
+	for _, noneMatch := range []string{etag, "W/" + etag, `"a", ` + etag, "*"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, noneMatch)
+		resp, err = app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, fiber.StatusNotModified, resp.StatusCode, noneMatch)
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "", string(body))
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"a", "b"`)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+}
+
+// go test -run Test_ETag_Weak_Hash
+func Test_ETag_Weak_Hash(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Weak: true,
+		Hash: func() hash.Hash {
+			return sha256.New()
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `W/"13-dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"`, resp.Header.Get(fiber.HeaderETag))
+
+	// Weak comparison
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"13-dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"`)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusNotModified, resp.StatusCode)
+}
+
+// go test -run Test_ETag_Skip
+func Test_ETag_Skip(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Next: func(c *fiber.Ctx) bool {
+			return c.Path() == "/next"
+		},
+	}))
+
+	app.Get("/next", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusNotFound).SendString("Not Found")
+	})
+	app.Get("/empty", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/stream", func(c *fiber.Ctx) error {
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("Hello, World!")
+		})
+	})
+
+	for _, path := range []string{"/next", "/error", "/empty", "/stream"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderETag), path)
+	}
+}
+
+// go test -run Test_ETag_Custom
+func Test_ETag_Custom(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderETag, `"custom"`)
+		return c.SendString("Hello, World!")
+	})
+	app.Put("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"custom"`)
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusNotModified, resp.StatusCode)
+	utils.AssertEqual(t, `"custom"`, resp.Header.Get(fiber.HeaderETag))
+
+	// Unsafe methods are never answered with 304
+	req = httptest.NewRequest("PUT", "/", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, "*")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, true, resp.Header.Get(fiber.HeaderETag) != "")
+}
+
+// go test -v -run=^$ -bench=Benchmark_ETag -benchmem -count=4
+func Benchmark_ETag(b *testing.B) {
+	app := fiber.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod("GET")
+	fctx.Request.SetRequestURI("/")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+
+	utils.AssertEqual(b, 200, fctx.Response.StatusCode())
+}
@@ -37,6 +37,13 @@ type Config struct {
 	//
 	// Optional. Default: ""
 	NotFoundFile string
+
+	// Precompressed serves the file with the .br, .zst or .gz extension
+	// added instead of the file, if it exists and the client accepts the
+	// encoding. The compress middleware doesn't compress these again.
+	//
+	// Optional. Default: false
+	Precompressed bool
 }
 
 // ConfigDefault is the default config
@@ -99,7 +106,8 @@ func New(config Config) fiber.Handler {
 
 		file, err := cfg.Root.Open(path)
 		if err != nil && os.IsNotExist(err) && cfg.NotFoundFile != "" {
-			file, err = cfg.Root.Open(cfg.NotFoundFile)
+			path = cfg.NotFoundFile
+			file, err = cfg.Root.Open(path)
 		}
 
 		if err != nil {
@@ -123,6 +131,7 @@ func New(config Config) fiber.Handler {
 				if err == nil {
 					file = index
 					stat = indexStat
+					path = indexPath
 				}
 			}
 		}
@@ -149,9 +158,21 @@ func New(config Config) fiber.Handler {
 			c.Set(fiber.HeaderLastModified, modTime.UTC().Format(http.TimeFormat))
 		}
 
+		// Serve a precompressed file instead if there is one
+		if cfg.Precompressed {
+			c.Vary(fiber.HeaderAcceptEncoding)
+			if compressed, size, encoding := openPrecompressed(cfg.Root, path, c.Get(fiber.HeaderAcceptEncoding)); compressed != nil {
+				if err := file.Close(); err != nil {
+					return err
+				}
+				file = compressed
+				contentLength = size
+				c.Set(fiber.HeaderContentEncoding, encoding)
+			}
+		}
+
 		if method == fiber.MethodGet {
-			c.Response().SetBodyStream(file, contentLength)
-			return nil
+			return c.SendStream(file, contentLength)
 		}
 		if method == fiber.MethodHead {
 			c.Request().ResetBody()
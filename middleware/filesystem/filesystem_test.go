@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
@@ -110,3 +112,46 @@ func Test_FileSystem(t *testing.T) {
 		})
 	}
 }
+
+// go test -run Test_FileSystem_Precompressed
+func Test_FileSystem_Precompressed(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Root:          http.Dir("../../.github/testdata/fs"),
+		Precompressed: true,
+	}))
+
+	plain, err := ioutil.ReadFile("../../.github/testdata/fs/css/style.css")
+	utils.AssertEqual(t, nil, err)
+	compressed, err := ioutil.ReadFile("../../.github/testdata/fs/css/style.css.gz")
+	utils.AssertEqual(t, nil, err)
+
+	for acceptEncoding, encoding := range map[string]string{
+		"gzip, deflate": "gzip",
+		"br":            "",
+		"gzip;q=0":      "",
+		"":              "",
+		// Missing files fall back to the next best encoding
+		"*":                 "gzip",
+		"br, gzip;q=0.5":    "gzip",
+		"*;q=0.5, gzip;q=0": "",
+	} {
+		req := httptest.NewRequest("GET", "/css/style.css", nil)
+		req.Header.Set(fiber.HeaderAcceptEncoding, acceptEncoding)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+		utils.AssertEqual(t, "text/css", resp.Header.Get(fiber.HeaderContentType))
+		utils.AssertEqual(t, fiber.HeaderAcceptEncoding, resp.Header.Get(fiber.HeaderVary))
+		utils.AssertEqual(t, encoding, resp.Header.Get(fiber.HeaderContentEncoding), acceptEncoding)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		if encoding == "" {
+			utils.AssertEqual(t, plain, body)
+		} else {
+			utils.AssertEqual(t, compressed, body)
+		}
+	}
+}
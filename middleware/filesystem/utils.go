@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 func getFileExtension(path string) string {
@@ -63,3 +64,47 @@ func dirList(c *fiber.Ctx, f http.File) error {
 
 	return nil
 }
+
+// precompressedEncodings are the encodings of precompressed files in order
+// of preference
+var precompressedEncodings = []string{"br", "zstd", "gzip"}
+
+// precompressedExtensions maps the encodings to the extensions of
+// precompressed files
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+// openPrecompressed opens the precompressed file of name with the encoding
+// the Accept-Encoding header prefers among the files that exist
+func openPrecompressed(fs http.FileSystem, name, acceptEncoding string) (http.File, int, string) {
+	encodings := precompressedEncodings
+	for {
+		encoding := utils.NegotiateEncoding(acceptEncoding, encodings...)
+		if encoding == "" {
+			return nil, 0, ""
+		}
+
+		// Try the next best encoding if there is no such file
+		remaining := make([]string, 0, len(encodings)-1)
+		for _, e := range encodings {
+			if e != encoding {
+				remaining = append(remaining, e)
+			}
+		}
+		encodings = remaining
+
+		file, err := fs.Open(name + precompressedExtensions[encoding])
+		if err != nil {
+			continue
+		}
+		stat, err := file.Stat()
+		if err != nil || stat.IsDir() {
+			_ = file.Close()
+			continue
+		}
+		return file, int(stat.Size()), encoding
+	}
+}
@@ -1,12 +1,12 @@
 package limiter
 
 import (
+	"encoding/binary"
 	"strconv"
-	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
 )
 
 // Config defines the config for middleware.
@@ -21,6 +21,13 @@ type Config struct {
 	// Default: 5
 	Max int
 
+	// MaxFunc returns the max number of connections for a request, it takes
+	// precedence over Max and allows limits per route or per user. A value
+	// below 1 is treated as 1.
+	//
+	// Default: nil
+	MaxFunc func(c *fiber.Ctx) int
+
 	// Duration is the time on how long to keep records of requests in memory
 	//
 	// Default: time.Minute
@@ -39,6 +46,44 @@ type Config struct {
 	//   return c.SendStatus(fiber.StatusTooManyRequests)
 	// }
 	LimitReached fiber.Handler
+
+	// When set to true, requests with a status code >= 400 or that return an error won't be counted.
+	//
+	// Default: false
+	SkipFailedRequests bool
+
+	// When set to true, requests with a status code < 400 won't be counted.
+	//
+	// Default: false
+	SkipSuccessfulRequests bool
+
+	// LimiterMiddleware is the algorithm used to limit the requests:
+	// FixedWindow{}, SlidingWindow{} or TokenBucket{}.
+	//
+	// Default: FixedWindow{}
+	LimiterMiddleware LimiterHandler
+
+	// Burst is the capacity of the bucket when TokenBucket{} is used, the
+	// bucket is refilled with Max tokens every Duration.
+	//
+	// Default: Max
+	Burst int
+
+	// StandardHeaders replaces the X-RateLimit-* headers by the RateLimit-Limit,
+	// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers of the
+	// IETF draft, which are also sent on rejected requests.
+	// https://tools.ietf.org/html/draft-ietf-httpapi-ratelimit-headers
+	//
+	// Default: false
+	StandardHeaders bool
+
+	// Storage is used to store the state of the middleware, use a shared
+	// storage to limit requests across processes when Prefork is enabled.
+	// Updates are only serialized within a process, so across processes the
+	// limit is enforced on a best-effort basis.
+	//
+	// Default: an in-memory storage
+	Storage fiber.Storage
 }
 
 // ConfigDefault is the default config
@@ -52,6 +97,12 @@ var ConfigDefault = Config{
 	LimitReached: func(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusTooManyRequests)
 	},
+	LimiterMiddleware: FixedWindow{},
+}
+
+// LimiterHandler is implemented by the limiting algorithms
+type LimiterHandler interface {
+	New(config Config) fiber.Handler
 }
 
 // X-RateLimit-* headers
@@ -61,6 +112,14 @@ const (
 	xRateLimitReset     = "X-RateLimit-Reset"
 )
 
+// RateLimit-* headers of the IETF draft
+const (
+	rateLimitLimit     = "RateLimit-Limit"
+	rateLimitRemaining = "RateLimit-Remaining"
+	rateLimitReset     = "RateLimit-Reset"
+	rateLimitPolicy    = "RateLimit-Policy"
+)
+
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
 	// Set default config
@@ -86,79 +145,103 @@ func New(config ...Config) fiber.Handler {
 		if cfg.LimitReached == nil {
 			cfg.LimitReached = ConfigDefault.LimitReached
 		}
+		if cfg.LimiterMiddleware == nil {
+			cfg.LimiterMiddleware = ConfigDefault.LimiterMiddleware
+		}
+	}
+	if cfg.Storage == nil {
+		cfg.Storage = memory.New()
 	}
 
-	// Limiter settings
-	var max = strconv.Itoa(cfg.Max)
-	var hits = make(map[string]int)
-	var reset = make(map[string]uint64)
-	var timestamp = uint64(time.Now().Unix())
-	var duration = uint64(cfg.Duration.Seconds())
-
-	// mutex for parallel read and write access
-	mux := &sync.Mutex{}
-
-	// Update timestamp every second
-	go func() {
-		for {
-			atomic.StoreUint64(&timestamp, uint64(time.Now().Unix()))
-			time.Sleep(time.Second)
-		}
-	}()
+	// Return the handler of the selected algorithm
+	return cfg.LimiterMiddleware.New(cfg)
+}
 
-	// Return new handler
-	return func(c *fiber.Ctx) error {
-		// Don't execute middleware if Next returns true
-		if cfg.Next != nil && cfg.Next(c) {
-			return c.Next()
+// max returns the limit for the request, at least 1 so the rates and
+// retry times stay finite
+func (cfg *Config) max(c *fiber.Ctx) int {
+	if cfg.MaxFunc != nil {
+		if max := cfg.MaxFunc(c); max > 0 {
+			return max
 		}
+		return 1
+	}
+	return cfg.Max
+}
 
-		// Get key (default is the remote IP)
-		key := cfg.Key(c)
-
-		// Lock map
-		mux.Lock()
+// skip reports whether a handled request should not be counted
+func (cfg *Config) skip(c *fiber.Ctx, err error) bool {
+	failed := err != nil || c.Response().StatusCode() >= fiber.StatusBadRequest
+	return (failed && cfg.SkipFailedRequests) || (!failed && cfg.SkipSuccessfulRequests)
+}
 
-		// Set unix timestamp if not exist
-		ts := atomic.LoadUint64(&timestamp)
-		if reset[key] == 0 {
-			reset[key] = ts + duration
-		} else if ts >= reset[key] {
-			hits[key] = 0
-			reset[key] = ts + duration
-		}
+// setHeaders sets the RateLimit headers of an accepted request
+func (cfg *Config) setHeaders(c *fiber.Ctx, limit, remaining int, reset uint64, policy string) {
+	if cfg.StandardHeaders {
+		c.Set(rateLimitLimit, strconv.Itoa(limit))
+		c.Set(rateLimitRemaining, strconv.Itoa(remaining))
+		c.Set(rateLimitReset, strconv.FormatUint(reset, 10))
+		c.Set(rateLimitPolicy, policy)
+		return
+	}
+	c.Set(xRateLimitLimit, strconv.Itoa(limit))
+	c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
+	c.Set(xRateLimitReset, strconv.FormatUint(reset, 10))
+}
 
-		// Increment key hits
-		hits[key]++
+// limitReached sets the Retry-After header and calls the LimitReached handler
+func (cfg *Config) limitReached(c *fiber.Ctx, limit int, retryAfter uint64, policy string) error {
+	// Tell clients following the IETF draft when they can send again
+	if cfg.StandardHeaders {
+		c.Set(rateLimitLimit, strconv.Itoa(limit))
+		c.Set(rateLimitRemaining, "0")
+		c.Set(rateLimitReset, strconv.FormatUint(retryAfter, 10))
+		c.Set(rateLimitPolicy, policy)
+	}
 
-		// Get current hits
-		hitCount := hits[key]
+	// Return response with Retry-After header
+	// https://tools.ietf.org/html/rfc6584
+	c.Set(fiber.HeaderRetryAfter, strconv.FormatUint(retryAfter, 10))
 
-		// Calculate when it resets in seconds
-		resetTime := reset[key] - ts
+	// Call LimitReached handler
+	return cfg.LimitReached(c)
+}
 
-		// Unlock map
-		mux.Unlock()
+// policy returns the RateLimit-Policy of a quota of limit requests per window
+func policy(limit int, window time.Duration) string {
+	return strconv.Itoa(limit) + ";w=" + strconv.FormatInt(int64(window/time.Second), 10)
+}
 
-		// Set how many hits we have left
-		remaining := cfg.Max - hitCount
+// now returns the current unix time in seconds
+func now() uint64 {
+	return uint64(time.Now().Unix())
+}
 
-		// Check if hits exceed the cfg.Max
-		if remaining < 0 {
-			// Return response with Retry-After header
-			// https://tools.ietf.org/html/rfc6584
-			c.Set(fiber.HeaderRetryAfter, strconv.FormatUint(resetTime, 10))
+// keyPrefix separates the keys of the limiter from those of other
+// middlewares sharing the storage
+const keyPrefix = "limiter:"
 
-			// Call LimitReached handler
-			return cfg.LimitReached(c)
+// getState reads the fields of a key from the storage, missing keys are all zero
+func getState(storage fiber.Storage, key string, fields []uint64) error {
+	raw, err := storage.Get(keyPrefix + key)
+	if err != nil {
+		return err
+	}
+	for i := range fields {
+		if len(raw) == len(fields)*8 {
+			fields[i] = binary.BigEndian.Uint64(raw[i*8:])
+		} else {
+			fields[i] = 0
 		}
+	}
+	return nil
+}
 
-		// We can continue, update RateLimit headers
-		c.Set(xRateLimitLimit, max)
-		c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
-		c.Set(xRateLimitReset, strconv.FormatUint(resetTime, 10))
-
-		// Continue stack
-		return c.Next()
+// setState writes the fields of a key to the storage
+func setState(storage fiber.Storage, key string, fields []uint64, exp time.Duration) error {
+	raw := make([]byte, len(fields)*8)
+	for i, field := range fields {
+		binary.BigEndian.PutUint64(raw[i*8:], field)
 	}
+	return storage.Set(keyPrefix+key, raw, exp)
 }
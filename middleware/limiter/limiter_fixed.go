@@ -0,0 +1,99 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FixedWindow counts the requests in consecutive windows of Duration, the
+// counter is reset at the end of each window. Clients can send up to twice
+// the limit around the end of a window.
+type FixedWindow struct{}
+
+// New creates a new fixed window middleware handler
+func (FixedWindow) New(cfg Config) fiber.Handler {
+	var (
+		// mutex for parallel read and write access, it doesn't serialize
+		// the processes sharing a Storage
+		mux      = &sync.Mutex{}
+		duration = uint64(cfg.Duration.Seconds())
+	)
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Get key (default is the remote IP) and limit
+		key := cfg.Key(c)
+		max := cfg.max(c)
+
+		// state is hits, end of the window
+		state := make([]uint64, 2)
+
+		// Lock storage
+		mux.Lock()
+
+		if err := getState(cfg.Storage, key, state); err != nil {
+			mux.Unlock()
+			return err
+		}
+
+		// Start a new window if there is none or it has ended
+		ts := now()
+		if ts >= state[1] {
+			state[0] = 0
+			state[1] = ts + duration
+		}
+
+		// Increment key hits
+		state[0]++
+		hits := state[0]
+		window := state[1]
+
+		// Calculate when it resets in seconds
+		resetTime := state[1] - ts
+
+		// Store the state until the window ends, expired keys are evicted by the storage
+		err := setState(cfg.Storage, key, state, time.Duration(resetTime)*time.Second)
+
+		// Unlock storage
+		mux.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		// Set how many hits we have left
+		remaining := max - int(hits)
+
+		// Check if hits exceed the max
+		if remaining < 0 {
+			return cfg.limitReached(c, max, resetTime, policy(max, cfg.Duration))
+		}
+
+		// We can continue, update RateLimit headers
+		cfg.setHeaders(c, max, remaining, resetTime, policy(max, cfg.Duration))
+
+		// Continue stack
+		err = c.Next()
+
+		// Don't count the request if it should be skipped
+		if cfg.skip(c, err) {
+			mux.Lock()
+			if getState(cfg.Storage, key, state) == nil && state[1] == window && state[0] > 0 {
+				state[0]--
+				if ts = now(); state[1] > ts {
+					_ = setState(cfg.Storage, key, state, time.Duration(state[1]-ts)*time.Second)
+				}
+			}
+			mux.Unlock()
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SlidingWindow approximates a sliding window of Duration by weighting the
+// hits of the previous window with the part of it that still overlaps the
+// sliding window. It smooths out the bursts a FixedWindow allows at the end
+// of a window. Rejected requests are not counted.
+type SlidingWindow struct{}
+
+// New creates a new sliding window middleware handler
+func (SlidingWindow) New(cfg Config) fiber.Handler {
+	var (
+		// mutex for parallel read and write access, it doesn't serialize
+		// the processes sharing a Storage
+		mux      = &sync.Mutex{}
+		duration = uint64(cfg.Duration.Seconds())
+	)
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Get key (default is the remote IP) and limit
+		key := cfg.Key(c)
+		max := cfg.max(c)
+
+		// state is hits of the current window, hits of the previous window, end of the current window
+		state := make([]uint64, 3)
+
+		// Lock storage
+		mux.Lock()
+
+		if err := getState(cfg.Storage, key, state); err != nil {
+			mux.Unlock()
+			return err
+		}
+
+		// Move the windows forward
+		ts := now()
+		if state[2] == 0 {
+			state[2] = ts + duration
+		} else if ts >= state[2] {
+			if ts >= state[2]+duration {
+				state[1] = 0
+			} else {
+				state[1] = state[0]
+			}
+			state[0] = 0
+			state[2] += duration * ((ts-state[2])/duration + 1)
+		}
+
+		// Seconds left in the current window
+		left := state[2] - ts
+
+		// Weighted hits of the sliding window, including this request
+		weight := float64(left) / float64(duration)
+		rate := int(float64(state[1])*weight) + int(state[0]) + 1
+		remaining := max - rate
+
+		if remaining < 0 {
+			mux.Unlock()
+			return cfg.limitReached(c, max, retryAfter(state[1], state[0], uint64(max), left, duration), policy(max, cfg.Duration))
+		}
+
+		// Increment key hits
+		state[0]++
+		window := state[2]
+
+		// Keep the state while it is used as the previous window
+		err := setState(cfg.Storage, key, state, time.Duration(left+duration)*time.Second)
+
+		// Unlock storage
+		mux.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		// We can continue, update RateLimit headers
+		cfg.setHeaders(c, max, remaining, left, policy(max, cfg.Duration))
+
+		// Continue stack
+		err = c.Next()
+
+		// Don't count the request if it should be skipped
+		if cfg.skip(c, err) {
+			mux.Lock()
+			if getState(cfg.Storage, key, state) == nil && state[2] == window && state[0] > 0 {
+				state[0]--
+				if ts = now(); state[2] > ts {
+					_ = setState(cfg.Storage, key, state, time.Duration(state[2]-ts+duration)*time.Second)
+				}
+			}
+			mux.Unlock()
+		}
+
+		return err
+	}
+}
+
+// retryAfter calculates the seconds until the weighted hits drop low enough
+// to accept another request
+func retryAfter(prev, hits, max, left, duration uint64) uint64 {
+	d := float64(duration)
+	if hits < max {
+		// Wait until enough hits of the previous window slide out
+		if prev == 0 {
+			return 0
+		}
+		t := math.Floor(float64(left)-float64(max-hits)*d/float64(prev)) + 1
+		return uint64(math.Max(1, t))
+	}
+	// Wait until enough hits of the current window, which becomes
+	// the previous window, slide out
+	s := math.Floor(d-float64(max)*d/float64(hits)) + 1
+	return left + uint64(math.Max(0, s))
+}
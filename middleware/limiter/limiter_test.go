@@ -4,10 +4,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gofiber/fiber/v2/storage/memory"
 	"github.com/gofiber/fiber/v2/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -61,6 +63,315 @@ func Test_Limiter_Concurrency(t *testing.T) {
 	utils.AssertEqual(t, 200, resp.StatusCode)
 }
 
+// go test -run Test_Limiter_Storage
+func Test_Limiter_Storage(t *testing.T) {
+	// Two apps sharing a storage, like the children of a prefork app
+	storage := memory.New()
+	defer storage.Close()
+
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		app.Use(New(Config{
+			Max:      2,
+			Duration: time.Minute,
+			Storage:  storage,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+		return app
+	}
+	app1, app2 := newApp(), newApp()
+
+	resp, err := app1.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+	utils.AssertEqual(t, "1", resp.Header.Get("X-RateLimit-Remaining"))
+
+	resp, err = app2.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+	utils.AssertEqual(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
+
+	resp, err = app1.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_Sliding_Window
+func Test_Limiter_Sliding_Window(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Max:               2,
+		Duration:          2 * time.Second,
+		LimiterMiddleware: SlidingWindow{},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	// Start right after the beginning of a second so the sleeps below end in a known window
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second + 50*time.Millisecond)))
+
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+	utils.AssertEqual(t, true, resp.Header.Get(fiber.HeaderRetryAfter) != "0")
+
+	// The hits of the previous window still count right after it ended
+	time.Sleep(2 * time.Second)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+
+	time.Sleep(2 * time.Second)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_Token_Bucket
+func Test_Limiter_Token_Bucket(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Max:               10,
+		Duration:          time.Second,
+		Burst:             3,
+		LimiterMiddleware: TokenBucket{},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	for i := 2; i >= 0; i-- {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+		utils.AssertEqual(t, "3", resp.Header.Get("X-RateLimit-Limit"))
+		utils.AssertEqual(t, strconv.Itoa(i), resp.Header.Get("X-RateLimit-Remaining"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+	utils.AssertEqual(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+
+	// A token is added every 100ms
+	time.Sleep(150 * time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_Skip_Failed_Requests
+func Test_Limiter_Skip_Failed_Requests(t *testing.T) {
+	t.Parallel()
+	for _, algorithm := range []LimiterHandler{FixedWindow{}, SlidingWindow{}, TokenBucket{}} {
+		app := fiber.New()
+
+		app.Use(New(Config{
+			Max:                1,
+			Duration:           time.Minute,
+			SkipFailedRequests: true,
+			LimiterMiddleware:  algorithm,
+		}))
+
+		app.Get("/:status", func(c *fiber.Ctx) error {
+			if c.Params("status") == "fail" {
+				return c.SendStatus(400)
+			}
+			return c.SendStatus(200)
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/fail", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 400, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/success", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/success", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 429, resp.StatusCode)
+	}
+}
+
+// go test -run Test_Limiter_Skip_Successful_Requests
+func Test_Limiter_Skip_Successful_Requests(t *testing.T) {
+	t.Parallel()
+	for _, algorithm := range []LimiterHandler{FixedWindow{}, SlidingWindow{}, TokenBucket{}} {
+		app := fiber.New()
+
+		app.Use(New(Config{
+			Max:                    1,
+			Duration:               time.Minute,
+			SkipSuccessfulRequests: true,
+			LimiterMiddleware:      algorithm,
+		}))
+
+		app.Get("/:status", func(c *fiber.Ctx) error {
+			if c.Params("status") == "fail" {
+				return fiber.ErrBadRequest
+			}
+			return c.SendStatus(200)
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/success", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/fail", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 400, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/success", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 429, resp.StatusCode)
+	}
+}
+
+// go test -run Test_Limiter_MaxFunc
+func Test_Limiter_MaxFunc(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		MaxFunc: func(c *fiber.Ctx) int {
+			if c.Path() == "/strict" {
+				return 1
+			}
+			return 10
+		},
+		Key: func(c *fiber.Ctx) string {
+			return c.IP() + c.Path()
+		},
+		Duration: time.Minute,
+	}))
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/strict", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+	utils.AssertEqual(t, "1", resp.Header.Get("X-RateLimit-Limit"))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/strict", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/relaxed", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+	utils.AssertEqual(t, "10", resp.Header.Get("X-RateLimit-Limit"))
+	utils.AssertEqual(t, "9", resp.Header.Get("X-RateLimit-Remaining"))
+
+	// A limit below 1 is treated as 1 by all algorithms
+	for _, algorithm := range []LimiterHandler{FixedWindow{}, SlidingWindow{}, TokenBucket{}} {
+		app := fiber.New()
+		app.Use(New(Config{
+			MaxFunc: func(c *fiber.Ctx) int {
+				return 0
+			},
+			LimiterMiddleware: algorithm,
+		}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 200, resp.StatusCode)
+		utils.AssertEqual(t, "1", resp.Header.Get("X-RateLimit-Limit"))
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 429, resp.StatusCode)
+		utils.AssertEqual(t, true, resp.Header.Get(fiber.HeaderRetryAfter) != "0")
+	}
+}
+
+// go test -run Test_Limiter_Standard_Headers
+func Test_Limiter_Standard_Headers(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(New(Config{
+		Max:             1,
+		Duration:        time.Minute,
+		StandardHeaders: true,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 200, resp.StatusCode)
+	utils.AssertEqual(t, "1", resp.Header.Get("RateLimit-Limit"))
+	utils.AssertEqual(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	utils.AssertEqual(t, true, resp.Header.Get("RateLimit-Reset") != "")
+	utils.AssertEqual(t, "1;w=60", resp.Header.Get("RateLimit-Policy"))
+	utils.AssertEqual(t, "", resp.Header.Get("X-RateLimit-Limit"))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 429, resp.StatusCode)
+	utils.AssertEqual(t, "1", resp.Header.Get("RateLimit-Limit"))
+	utils.AssertEqual(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	utils.AssertEqual(t, resp.Header.Get(fiber.HeaderRetryAfter), resp.Header.Get("RateLimit-Reset"))
+	utils.AssertEqual(t, "1;w=60", resp.Header.Get("RateLimit-Policy"))
+
+	// Token bucket announces its burst
+	app = fiber.New()
+	app.Use(New(Config{
+		Max:               10,
+		Duration:          time.Second,
+		Burst:             20,
+		LimiterMiddleware: TokenBucket{},
+		StandardHeaders:   true,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "20", resp.Header.Get("RateLimit-Limit"))
+	utils.AssertEqual(t, "19", resp.Header.Get("RateLimit-Remaining"))
+	utils.AssertEqual(t, "10;w=1;burst=20", resp.Header.Get("RateLimit-Policy"))
+}
+
+// go test -run Test_Limiter_retryAfter
+func Test_Limiter_retryAfter(t *testing.T) {
+	t.Parallel()
+	// Limit is reached by the current window, wait for the next window and
+	// until half of its hits have slid out
+	utils.AssertEqual(t, uint64(5+6), retryAfter(0, 4, 2, 5, 10))
+	utils.AssertEqual(t, uint64(5+1), retryAfter(0, 2, 2, 5, 10))
+	// Limit is reached by the previous window
+	utils.AssertEqual(t, uint64(6), retryAfter(4, 0, 2, 10, 10))
+	utils.AssertEqual(t, uint64(0), retryAfter(0, 0, 2, 10, 10))
+}
+
 // go test -v -run=^$ -bench=Benchmark_Limiter -benchmem -count=4
 func Benchmark_Limiter(b *testing.B) {
 	app := fiber.New()
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TokenBucket allows bursts of up to Burst requests, every request takes a
+// token from the bucket, which is refilled at a rate of Max tokens per
+// Duration. Rejected requests don't take a token.
+type TokenBucket struct{}
+
+// New creates a new token bucket middleware handler
+func (TokenBucket) New(cfg Config) fiber.Handler {
+	// mutex for parallel read and write access, it doesn't serialize
+	// the processes sharing a Storage
+	mux := &sync.Mutex{}
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Get key (default is the remote IP) and limit
+		key := cfg.Key(c)
+		max := cfg.max(c)
+		capacity := float64(cfg.Burst)
+		if cfg.Burst <= 0 {
+			capacity = float64(max)
+		}
+
+		// Tokens per nanosecond
+		rate := float64(max) / float64(cfg.Duration)
+		quota := policy(max, cfg.Duration) + ";burst=" + strconv.Itoa(int(capacity))
+
+		// state is tokens as float64 bits, time of the last refill in unix nanoseconds
+		state := make([]uint64, 2)
+
+		// Lock storage
+		mux.Lock()
+
+		if err := getState(cfg.Storage, key, state); err != nil {
+			mux.Unlock()
+			return err
+		}
+
+		// Refill the bucket, a missing key is a full bucket
+		ts := time.Now().UnixNano()
+		tokens := capacity
+		if state[1] != 0 {
+			tokens = math.Min(capacity, math.Float64frombits(state[0])+float64(ts-int64(state[1]))*rate)
+		}
+
+		if tokens < 1 {
+			mux.Unlock()
+			return cfg.limitReached(c, int(capacity), nanosToSeconds((1-tokens)/rate), quota)
+		}
+
+		// Take a token
+		tokens--
+		state[0] = math.Float64bits(tokens)
+		state[1] = uint64(ts)
+
+		// Keep the state until the bucket is full again
+		full := nanosToSeconds((capacity - tokens) / rate)
+		err := setState(cfg.Storage, key, state, time.Duration(full)*time.Second)
+
+		// Unlock storage
+		mux.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		// We can continue, update RateLimit headers
+		cfg.setHeaders(c, int(capacity), int(tokens), full, quota)
+
+		// Continue stack
+		err = c.Next()
+
+		// Return the token if the request should be skipped
+		if cfg.skip(c, err) {
+			mux.Lock()
+			if getState(cfg.Storage, key, state) == nil && state[1] != 0 {
+				tokens = math.Min(capacity, math.Float64frombits(state[0])+1)
+				state[0] = math.Float64bits(tokens)
+				_ = setState(cfg.Storage, key, state, time.Duration(nanosToSeconds((capacity-tokens)/rate)+1)*time.Second)
+			}
+			mux.Unlock()
+		}
+
+		return err
+	}
+}
+
+// nanosToSeconds rounds nanoseconds up to whole seconds
+func nanosToSeconds(ns float64) uint64 {
+	return uint64(math.Ceil(ns / float64(time.Second)))
+}
@@ -1,16 +1,22 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/internal/bytebufferpool"
+	"github.com/gofiber/fiber/v2/internal/encoding/json"
 	"github.com/gofiber/fiber/v2/internal/fasttemplate"
+	"github.com/gofiber/fiber/v2/internal/isatty"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 // Config defines the config for middleware.
@@ -27,7 +33,7 @@ type Config struct {
 
 	// TimeFormat https://programming.guide/go/format-parse-string-time-date-example.html
 	//
-	// Optional. Default: 15:04:05
+	// Optional. Default: 15:04:05, or time.RFC3339 in JSON mode
 	TimeFormat string
 
 	// TimeZone can be specified, such as "UTC" and "America/New_York" and "Asia/Chongqing", etc
@@ -39,7 +45,60 @@ type Config struct {
 	// Default: os.Stderr
 	Output io.Writer
 
+	// JSON writes one JSON object per request with the Fields as keys
+	// instead of the Format. The status, latency in milliseconds, pid and
+	// bytes are numbers, the error and the unknown size of a streamed
+	// response are null.
+	//
+	// Optional. Default: false
+	JSON bool
+
+	// Fields defines the tags written in JSON mode, in order.
+	//
+	// Optional. Default: []string{"time", "status", "latency", "method", "path",
+	// "route", "ip", "bytesSent", "bytesReceived", "requestID", "error"}
+	Fields []string
+
+	// CustomTags defines additional tags for the Format and Fields, or
+	// overrides the built-in ones. The value is written with fmt.Sprint in
+	// text mode and encoded as JSON in JSON mode.
+	//
+	// Optional. Default: nil
+	CustomTags map[string]func(c *fiber.Ctx) interface{}
+
+	// DisableColors disables the colors of the status and method tags,
+	// colors are only used when the Output is a terminal.
+	//
+	// Optional. Default: false
+	DisableColors bool
+
+	// Async writes the logs to the Output from a separate goroutine, so
+	// requests don't wait on a slow Output. Queued logs are flushed every
+	// FlushInterval and when the app is shut down with App.Shutdown.
+	//
+	// Optional. Default: false
+	Async bool
+
+	// QueueSize is the number of logs the Async queue holds.
+	//
+	// Optional. Default: 1024
+	QueueSize int
+
+	// DropOnFull discards logs when the Async queue is full instead of
+	// blocking the request until there is room. The number of discarded logs
+	// is written to the Output on the next flush.
+	//
+	// Optional. Default: false
+	DropOnFull bool
+
+	// FlushInterval is the interval in which the Async buffer is written to
+	// the Output.
+	//
+	// Optional. Default: 1 * time.Second
+	FlushInterval time.Duration
+
 	haveLatency      bool
+	enableColors     bool
 	timeZoneLocation *time.Location
 }
 
@@ -50,6 +109,10 @@ var ConfigDefault = Config{
 	TimeFormat: "15:04:05",
 	TimeZone:   "Local",
 	Output:     os.Stderr,
+	Fields: []string{TagTime, TagStatus, TagLatency, TagMethod, TagPath,
+		TagRoute, TagIP, TagBytesSent, TagBytesReceived, TagRequestID, TagError},
+	QueueSize:     1024,
+	FlushInterval: 1 * time.Second,
 }
 
 // Logger variables
@@ -68,14 +131,19 @@ const (
 	TagLatency       = "latency"
 	TagStatus        = "status"
 	TagBody          = "body"
+	TagResBody       = "resBody"
+	TagReqHeaders    = "reqHeaders"
+	TagQueryParams   = "queryParams"
 	TagBytesSent     = "bytesSent"
 	TagBytesReceived = "bytesReceived"
 	TagRoute         = "route"
+	TagRequestID     = "requestID"
 	TagError         = "error"
 	TagHeader        = "header:"
 	TagQuery         = "query:"
 	TagForm          = "form:"
 	TagCookie        = "cookie:"
+	TagLocals        = "locals:"
 	TagBlack         = "black"
 	TagRed           = "red"
 	TagGreen         = "green"
@@ -121,10 +189,22 @@ func New(config ...Config) fiber.Handler {
 		}
 		if cfg.TimeFormat == "" {
 			cfg.TimeFormat = ConfigDefault.TimeFormat
+			if cfg.JSON {
+				cfg.TimeFormat = time.RFC3339
+			}
 		}
 		if cfg.Output == nil {
 			cfg.Output = ConfigDefault.Output
 		}
+		if len(cfg.Fields) == 0 {
+			cfg.Fields = ConfigDefault.Fields
+		}
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = ConfigDefault.QueueSize
+		}
+		if cfg.FlushInterval <= 0 {
+			cfg.FlushInterval = ConfigDefault.FlushInterval
+		}
 	}
 
 	// Get timezone location
@@ -137,6 +217,17 @@ func New(config ...Config) fiber.Handler {
 
 	// Check if format contains latency
 	cfg.haveLatency = strings.Contains(cfg.Format, "${latency}")
+	if cfg.JSON {
+		cfg.haveLatency = false
+		for _, field := range cfg.Fields {
+			if field == TagLatency {
+				cfg.haveLatency = true
+			}
+		}
+	}
+
+	// Only use colors when writing to a terminal
+	cfg.enableColors = !cfg.DisableColors && !cfg.JSON && isTerminal(cfg.Output)
 
 	// Create template parser
 	tmpl := fasttemplate.New(cfg.Format, "${", "}")
@@ -145,12 +236,20 @@ func New(config ...Config) fiber.Handler {
 	var timestamp atomic.Value
 	timestamp.Store(time.Now().In(cfg.timeZoneLocation).Format(cfg.TimeFormat))
 
-	// Update date/time every 750 milliseconds in a separate go routine
-	if strings.Contains(cfg.Format, "${time}") {
+	// Update date/time every 750 milliseconds in a separate go routine,
+	// stopped when the app is shut down
+	done := make(chan struct{})
+	if strings.Contains(cfg.Format, "${time}") || cfg.JSON {
 		go func() {
+			ticker := time.NewTicker(750 * time.Millisecond)
+			defer ticker.Stop()
 			for {
-				time.Sleep(750 * time.Millisecond)
-				timestamp.Store(time.Now().In(cfg.timeZoneLocation).Format(cfg.TimeFormat))
+				select {
+				case <-ticker.C:
+					timestamp.Store(time.Now().In(cfg.timeZoneLocation).Format(cfg.TimeFormat))
+				case <-done:
+					return
+				}
 			}
 		}()
 	}
@@ -158,8 +257,25 @@ func New(config ...Config) fiber.Handler {
 	// Set PID once
 	pid := strconv.Itoa(os.Getpid())
 
-	// Set start and stop
-	var start, stop time.Time
+	// Write from a separate goroutine, flushed when the app is shut down
+	output := cfg.Output
+	var async *asyncWriter
+	if cfg.Async {
+		async = newAsyncWriter(cfg.Output, cfg.QueueSize, cfg.DropOnFull, cfg.FlushInterval)
+		output = async
+	}
+
+	// Stop the goroutines when the app is shut down, logs of a restarted
+	// app are written directly with the time of each request
+	var onShutdown, shutdown sync.Once
+	stopGoroutines := func() {
+		shutdown.Do(func() {
+			close(done)
+			if async != nil {
+				_ = async.Close()
+			}
+		})
+	}
 
 	// Return new handler
 	return func(c *fiber.Ctx) (err error) {
@@ -167,7 +283,12 @@ func New(config ...Config) fiber.Handler {
 		if cfg.Next != nil && cfg.Next(c) {
 			return c.Next()
 		}
+		onShutdown.Do(func() {
+			c.App().OnShutdown(stopGoroutines)
+		})
+
 		// Set latency start time
+		var start, stop time.Time
 		if cfg.haveLatency {
 			start = time.Now()
 		}
@@ -182,92 +303,72 @@ func New(config ...Config) fiber.Handler {
 		// Get new buffer
 		buf := bytebufferpool.Get()
 
-		// Loop over template tags to replace it with the correct value
-		_, err = tmpl.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
-			switch tag {
-			case TagTime:
-				return buf.WriteString(timestamp.Load().(string))
-			case TagReferer:
-				return buf.WriteString(c.Get(fiber.HeaderReferer))
-			case TagProtocol:
-				return buf.WriteString(c.Protocol())
-			case TagPid:
-				return buf.WriteString(pid)
-			case TagIP:
-				return buf.WriteString(c.IP())
-			case TagIPs:
-				return buf.WriteString(c.Get(fiber.HeaderXForwardedFor))
-			case TagHost:
-				return buf.WriteString(c.Hostname())
-			case TagPath:
-				return buf.WriteString(c.Path())
-			case TagURL:
-				return buf.WriteString(c.OriginalURL())
-			case TagUA:
-				return buf.WriteString(c.Get(fiber.HeaderUserAgent))
-			case TagLatency:
-				return buf.WriteString(stop.Sub(start).String())
-			case TagBody:
-				return buf.Write(c.Body())
-			case TagBytesReceived:
-				return buf.WriteString(strconv.Itoa(len(c.Request().Body())))
-			case TagBytesSent:
-				return buf.WriteString(strconv.Itoa(len(c.Request().Body())))
-			case TagRoute:
-				return buf.WriteString(c.Route().Path)
-			case TagStatus:
-				return buf.WriteString(strconv.Itoa(c.Response().StatusCode()))
-			case TagMethod:
-				return buf.WriteString(c.Method())
-			case TagBlack:
-				return buf.WriteString(cBlack)
-			case TagRed:
-				return buf.WriteString(cRed)
-			case TagGreen:
-				return buf.WriteString(cGreen)
-			case TagYellow:
-				return buf.WriteString(cYellow)
-			case TagBlue:
-				return buf.WriteString(cBlue)
-			case TagMagenta:
-				return buf.WriteString(cMagenta)
-			case TagCyan:
-				return buf.WriteString(cCyan)
-			case TagWhite:
-				return buf.WriteString(cWhite)
-			case TagReset:
-				return buf.WriteString(cReset)
-			case TagError:
-				if err != nil {
-					return buf.WriteString(err.Error())
+		d := &data{
+			pid:       pid,
+			timestamp: timestamp.Load().(string),
+			start:     start,
+			stop:      stop,
+			err:       err,
+		}
+		select {
+		case <-done:
+			d.timestamp = time.Now().In(cfg.timeZoneLocation).Format(cfg.TimeFormat)
+		default:
+		}
+
+		// The size and error of a stream are only known once it is sent, the
+		// line is written then with the bytes sent inserted at d.sentAt and
+		// the error replacing its placeholders at d.errAt
+		var line []byte
+		if bytesSent(c) < 0 {
+			d.streamed = c.OnStreamSent(func(n int64, streamErr error) {
+				var fills []fill
+				for _, at := range d.sentAt {
+					fills = append(fills, fill{at: at, value: strconv.FormatInt(n, 10)})
 				}
-				return buf.WriteString("-")
-			default:
-				// Check if we have a value tag i.e.: "header:x-key"
-				switch {
-				case strings.HasPrefix(tag, TagHeader):
-					return buf.WriteString(c.Get(tag[7:]))
-				case strings.HasPrefix(tag, TagQuery):
-					return buf.WriteString(c.Query(tag[6:]))
-				case strings.HasPrefix(tag, TagForm):
-					return buf.WriteString(c.FormValue(tag[5:]))
-				case strings.HasPrefix(tag, TagCookie):
-					return buf.WriteString(c.Cookies(tag[7:]))
+				if streamErr != nil {
+					placeholder, value := 1, streamErr.Error()
+					if cfg.JSON {
+						b, _ := json.Marshal(value)
+						placeholder, value = len("null"), string(b)
+					}
+					for _, at := range d.errAt {
+						fills = append(fills, fill{at: at, n: placeholder, value: value})
+					}
 				}
-			}
-			return 0, nil
-		})
+				// Fill in the later positions first so the earlier ones stay
+				// valid, a placeholder is replaced before inserting at its position
+				sort.Slice(fills, func(i, j int) bool {
+					if fills[i].at != fills[j].at {
+						return fills[i].at > fills[j].at
+					}
+					return fills[i].n > fills[j].n
+				})
+				for _, f := range fills {
+					line = append(line[:f.at], append([]byte(f.value), line[f.at+f.n:]...)...)
+				}
+				if _, err := output.Write(line); err != nil {
+					writeError(output, err)
+				}
+			})
+		}
+		if cfg.JSON {
+			err = writeJSON(buf, c, &cfg, d)
+		} else {
+			// Loop over template tags to replace it with the correct value
+			_, err = tmpl.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
+				return writeTag(buf, c, &cfg, d, tag)
+			})
+		}
 		// Also write errors to the buffer
 		if err != nil {
 			_, _ = buf.WriteString(err.Error())
 		}
 		// Write buffer to output
-		if _, err := cfg.Output.Write(buf.Bytes()); err != nil {
-			// Write error to output
-			if _, err := cfg.Output.Write([]byte(err.Error())); err != nil {
-				// There is something wrong with the given io.Writer
-				// TODO: What should we do here?
-			}
+		if d.streamed {
+			line = append(line, buf.Bytes()...)
+		} else if _, err := output.Write(buf.Bytes()); err != nil {
+			writeError(output, err)
 		}
 		// Put buffer back to pool
 		bytebufferpool.Put(buf)
@@ -275,3 +376,303 @@ func New(config ...Config) fiber.Handler {
 		return nil
 	}
 }
+
+// data holds the values of a request that are not stored in the Ctx
+type data struct {
+	pid       string
+	timestamp string
+	start     time.Time
+	stop      time.Time
+	err       error
+	streamed  bool  // The bytes sent are written once the stream is sent
+	sentAt    []int // Positions of the bytes sent of a stream in the line
+	errAt     []int // Positions of the error placeholders of a stream in the line
+}
+
+// fill replaces n bytes at the position at of a log line with value
+type fill struct {
+	at    int
+	n     int
+	value string
+}
+
+// writeTag writes the text value of tag to buf
+func writeTag(buf *bytebufferpool.ByteBuffer, c *fiber.Ctx, cfg *Config, d *data, tag string) (int, error) {
+	if custom, ok := cfg.CustomTags[tag]; ok {
+		return writeValue(buf, custom(c))
+	}
+
+	switch tag {
+	case TagTime:
+		return buf.WriteString(d.timestamp)
+	case TagReferer:
+		return buf.WriteString(c.Get(fiber.HeaderReferer))
+	case TagProtocol:
+		return buf.WriteString(c.Protocol())
+	case TagPid:
+		return buf.WriteString(d.pid)
+	case TagIP:
+		return buf.WriteString(c.IP())
+	case TagIPs:
+		return buf.WriteString(c.Get(fiber.HeaderXForwardedFor))
+	case TagHost:
+		return buf.WriteString(c.Hostname())
+	case TagPath:
+		return buf.WriteString(c.Path())
+	case TagURL:
+		return buf.WriteString(c.OriginalURL())
+	case TagUA:
+		return buf.WriteString(c.Get(fiber.HeaderUserAgent))
+	case TagLatency:
+		return buf.WriteString(d.stop.Sub(d.start).String())
+	case TagBody:
+		return buf.Write(c.Body())
+	case TagResBody:
+		return buf.Write(responseBody(c))
+	case TagReqHeaders:
+		n := 0
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			if n > 0 {
+				n++
+				_ = buf.WriteByte('&')
+			}
+			k, _ := buf.Write(key)
+			_ = buf.WriteByte('=')
+			v, _ := buf.Write(value)
+			n += k + 1 + v
+		})
+		return n, nil
+	case TagQueryParams:
+		return buf.Write(c.Request().URI().QueryString())
+	case TagBytesReceived:
+		return buf.WriteString(strconv.Itoa(bytesReceived(c)))
+	case TagBytesSent:
+		if d.streamed {
+			d.sentAt = append(d.sentAt, buf.Len())
+			return 0, nil
+		}
+		if n := bytesSent(c); n >= 0 {
+			return buf.WriteString(strconv.Itoa(n))
+		}
+		return buf.WriteString("-")
+	case TagRoute:
+		return buf.WriteString(c.Route().Path)
+	case TagRequestID:
+		return buf.WriteString(requestid.FromContext(c))
+	case TagStatus:
+		code := c.Response().StatusCode()
+		if cfg.enableColors {
+			return colorize(buf, statusColor(code), strconv.Itoa(code))
+		}
+		return buf.WriteString(strconv.Itoa(code))
+	case TagMethod:
+		if cfg.enableColors {
+			return colorize(buf, methodColor(c.Method()), c.Method())
+		}
+		return buf.WriteString(c.Method())
+	case TagBlack:
+		return buf.WriteString(cBlack)
+	case TagRed:
+		return buf.WriteString(cRed)
+	case TagGreen:
+		return buf.WriteString(cGreen)
+	case TagYellow:
+		return buf.WriteString(cYellow)
+	case TagBlue:
+		return buf.WriteString(cBlue)
+	case TagMagenta:
+		return buf.WriteString(cMagenta)
+	case TagCyan:
+		return buf.WriteString(cCyan)
+	case TagWhite:
+		return buf.WriteString(cWhite)
+	case TagReset:
+		return buf.WriteString(cReset)
+	case TagError:
+		if d.err != nil {
+			return buf.WriteString(d.err.Error())
+		}
+		if d.streamed {
+			d.errAt = append(d.errAt, buf.Len())
+		}
+		return buf.WriteString("-")
+	default:
+		// Check if we have a value tag i.e.: "header:x-key"
+		switch {
+		case strings.HasPrefix(tag, TagHeader):
+			return buf.WriteString(c.Get(tag[7:]))
+		case strings.HasPrefix(tag, TagQuery):
+			return buf.WriteString(c.Query(tag[6:]))
+		case strings.HasPrefix(tag, TagForm):
+			return buf.WriteString(c.FormValue(tag[5:]))
+		case strings.HasPrefix(tag, TagCookie):
+			return buf.WriteString(c.Cookies(tag[7:]))
+		case strings.HasPrefix(tag, TagLocals):
+			return writeValue(buf, c.Locals(tag[7:]))
+		}
+	}
+	return 0, nil
+}
+
+// writeValue writes the text of a custom tag or local to buf
+func writeValue(buf *bytebufferpool.ByteBuffer, v interface{}) (int, error) {
+	switch v := v.(type) {
+	case nil:
+		return buf.WriteString("-")
+	case string:
+		return buf.WriteString(v)
+	case []byte:
+		return buf.Write(v)
+	default:
+		return fmt.Fprint(buf, v)
+	}
+}
+
+// writeJSON writes the fields as a JSON object followed by a newline to buf
+func writeJSON(buf *bytebufferpool.ByteBuffer, c *fiber.Ctx, cfg *Config, d *data) error {
+	value := bytebufferpool.Get()
+	defer bytebufferpool.Put(value)
+
+	_ = buf.WriteByte('{')
+	for i, field := range cfg.Fields {
+		if i > 0 {
+			_ = buf.WriteByte(',')
+		}
+		if err := writeJSONValue(buf, field); err != nil {
+			return err
+		}
+		_ = buf.WriteByte(':')
+
+		var v interface{}
+		if custom, ok := cfg.CustomTags[field]; ok {
+			v = custom(c)
+		} else if strings.HasPrefix(field, TagLocals) {
+			v = c.Locals(field[7:])
+		} else {
+			switch field {
+			case TagStatus:
+				v = c.Response().StatusCode()
+			case TagLatency:
+				v = float64(d.stop.Sub(d.start).Nanoseconds()) / float64(time.Millisecond)
+			case TagPid:
+				v, _ = strconv.Atoi(d.pid)
+			case TagBytesReceived:
+				v = bytesReceived(c)
+			case TagBytesSent:
+				if d.streamed {
+					d.sentAt = append(d.sentAt, buf.Len())
+					continue
+				}
+				if n := bytesSent(c); n >= 0 {
+					v = n
+				}
+			case TagReqHeaders:
+				headers := make(map[string]string)
+				c.Request().Header.VisitAll(func(key, value []byte) {
+					if prev, ok := headers[string(key)]; ok {
+						headers[string(key)] = prev + ", " + string(value)
+					} else {
+						headers[string(key)] = string(value)
+					}
+				})
+				v = headers
+			case TagError:
+				if d.err != nil {
+					v = d.err.Error()
+				} else if d.streamed {
+					d.errAt = append(d.errAt, buf.Len())
+				}
+			default:
+				value.Reset()
+				if _, err := writeTag(value, c, cfg, d, field); err != nil {
+					return err
+				}
+				v = value.String()
+			}
+		}
+		if err := writeJSONValue(buf, v); err != nil {
+			return err
+		}
+	}
+	_, _ = buf.WriteString("}\n")
+	return nil
+}
+
+func writeJSONValue(buf *bytebufferpool.ByteBuffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = buf.Write(b)
+	return err
+}
+
+// bytesReceived returns the size of the request headers and body
+func bytesReceived(c *fiber.Ctx) int {
+	return len(c.Request().Header.Header()) + len(c.Request().Body())
+}
+
+// bytesSent returns the size of the response body, or -1 for a body
+// stream without a size which is only known after it is sent
+func bytesSent(c *fiber.Ctx) int {
+	if c.Response().IsBodyStream() {
+		return c.Response().Header.ContentLength()
+	}
+	return len(c.Response().Body())
+}
+
+// responseBody returns the response body, body streams are not read
+func responseBody(c *fiber.Ctx) []byte {
+	if c.Response().IsBodyStream() {
+		return nil
+	}
+	return c.Response().Body()
+}
+
+// isTerminal returns true if w is a terminal that supports colors
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+func colorize(buf *bytebufferpool.ByteBuffer, color, s string) (int, error) {
+	_, _ = buf.WriteString(color)
+	_, _ = buf.WriteString(s)
+	_, _ = buf.WriteString(cReset)
+	return len(color) + len(s) + len(cReset), nil
+}
+
+func statusColor(code int) string {
+	switch {
+	case code >= fiber.StatusInternalServerError:
+		return cRed
+	case code >= fiber.StatusBadRequest:
+		return cYellow
+	case code >= fiber.StatusMultipleChoices:
+		return cCyan
+	case code >= fiber.StatusOK:
+		return cGreen
+	default:
+		return cBlue
+	}
+}
+
+func methodColor(method string) string {
+	switch method {
+	case fiber.MethodGet:
+		return cCyan
+	case fiber.MethodPost:
+		return cGreen
+	case fiber.MethodPut, fiber.MethodPatch:
+		return cYellow
+	case fiber.MethodDelete:
+		return cRed
+	case fiber.MethodHead:
+		return cMagenta
+	default:
+		return cBlue
+	}
+}
@@ -1,13 +1,26 @@
 package logger
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/internal/bytebufferpool"
 	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
 )
 
 // go test -run Test_Logger
@@ -31,3 +44,577 @@ func Test_Logger(t *testing.T) {
 	utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
 	utils.AssertEqual(t, "some random error", buf.String())
 }
+
+// go test -run Test_Logger_JSON
+func Test_Logger_JSON(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		JSON:   true,
+		Fields: []string{TagStatus, TagMethod, TagRoute, TagBytesSent, TagRequestID, TagError, "header:X-Custom", "user_id"},
+		CustomTags: map[string]func(c *fiber.Ctx) interface{}{
+			"user_id": func(c *fiber.Ctx) interface{} {
+				return c.Locals("user_id")
+			},
+		},
+		Output: buf,
+	}))
+
+	app.Post("/users/:id", func(c *fiber.Ctx) error {
+		c.Locals("user_id", 42)
+		c.Set(fiber.HeaderXRequestID, "abc")
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return fiber.ErrTeapot
+	})
+
+	req := httptest.NewRequest("POST", "/users/1", strings.NewReader("hello"))
+	req.Header.Set("X-Custom", `"quoted"`)
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusCreated, resp.StatusCode)
+	utils.AssertEqual(t, `{"status":201,"method":"POST","route":"/users/:id","bytesSent":7,"requestID":"abc","error":null,"header:X-Custom":"\"quoted\"","user_id":42}`+"\n", buf.String())
+
+	buf.Reset()
+	_, err = app.Test(httptest.NewRequest("GET", "/error", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `{"status":418,"method":"GET","route":"/error","bytesSent":12,"requestID":"","error":"I'm a teapot","header:X-Custom":"","user_id":null}`+"\n", buf.String())
+}
+
+// go test -run Test_Logger_JSON_Default_Fields
+func Test_Logger_JSON_Default_Fields(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		JSON:   true,
+		Output: buf,
+	}))
+
+	_, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+
+	var entry map[string]interface{}
+	utils.AssertEqual(t, nil, json.Unmarshal(buf.Bytes(), &entry))
+	utils.AssertEqual(t, len(ConfigDefault.Fields), len(entry))
+	utils.AssertEqual(t, float64(fiber.StatusNotFound), entry[TagStatus])
+	_, ok := entry[TagLatency].(float64)
+	utils.AssertEqual(t, true, ok)
+	_, err = time.Parse(time.RFC3339, entry[TagTime].(string))
+	utils.AssertEqual(t, nil, err)
+}
+
+// go test -run Test_Logger_Custom_Tags
+func Test_Logger_Custom_Tags(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		Format: "${user_id} ${missing} ${method}",
+		CustomTags: map[string]func(c *fiber.Ctx) interface{}{
+			"user_id": func(c *fiber.Ctx) interface{} {
+				return c.Locals("user_id")
+			},
+			"missing": func(c *fiber.Ctx) interface{} {
+				return nil
+			},
+			// Override a built-in tag
+			TagMethod: func(c *fiber.Ctx) interface{} {
+				return strings.ToLower(c.Method())
+			},
+		},
+		Output: buf,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("user_id", 42)
+		return nil
+	})
+
+	_, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "42 - get", buf.String())
+}
+
+// go test -run Test_Logger_Bytes
+func Test_Logger_Bytes(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		Format: "${bytesSent} ${bytesReceived}",
+		Output: buf,
+	}))
+
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendString("response")
+	})
+	app.Get("/stream", func(c *fiber.Ctx) error {
+		return c.SendStream(strings.NewReader("streamed"), 8)
+	})
+	app.Get("/chunked", func(c *fiber.Ctx) error {
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("chunked")
+		})
+	})
+	app.Get("/raw", func(c *fiber.Ctx) error {
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("raw")
+		})
+		return nil
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("request"))
+	_, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	parts := strings.Split(buf.String(), " ")
+	utils.AssertEqual(t, "8", parts[0])
+	received, err := strconv.Atoi(parts[1])
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, received > len("request")+len("POST / HTTP/1.1\r\n"))
+
+	buf.Reset()
+	_, err = app.Test(httptest.NewRequest("GET", "/stream", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "8", strings.Split(buf.String(), " ")[0])
+
+	// The size of a stream without a size is logged once it is sent
+	buf.Reset()
+	_, err = app.Test(httptest.NewRequest("GET", "/chunked", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "7", strings.Split(buf.String(), " ")[0])
+
+	// The size of a stream set with fasthttp is unknown
+	buf.Reset()
+	_, err = app.Test(httptest.NewRequest("GET", "/raw", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "-", strings.Split(buf.String(), " ")[0])
+}
+
+// go test -run Test_Logger_Bytes_JSON
+func Test_Logger_Bytes_JSON(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		JSON:   true,
+		Fields: []string{TagStatus, TagBytesSent},
+		Output: buf,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("chunked")
+		})
+	})
+
+	_, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `{"status":200,"bytesSent":7}`+"\n", buf.String())
+}
+
+// go test -run Test_Logger_Stream_Error
+func Test_Logger_Stream_Error(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+	jsonBuf := bytebufferpool.Get()
+	defer bytebufferpool.Put(jsonBuf)
+
+	app.Use(New(Config{
+		Format: "${error} ${bytesSent}${error}\n",
+		Output: buf,
+	}))
+	app.Use(New(Config{
+		JSON:   true,
+		Fields: []string{TagError, TagBytesSent},
+		Output: jsonBuf,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SSE(func(w *fiber.SSEWriter) error {
+			return errors.New("boom")
+		}, -1)
+	})
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return c.SSE(func(w *fiber.SSEWriter) error {
+			return nil
+		}, -1)
+	})
+
+	// The error of the SSE handler is logged once the stream is sent
+	_, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "boom 6boom\n", buf.String())
+	utils.AssertEqual(t, `{"error":"boom","bytesSent":6}`+"\n", jsonBuf.String())
+
+	buf.Reset()
+	jsonBuf.Reset()
+	_, err = app.Test(httptest.NewRequest("GET", "/ok", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "- 6-\n", buf.String())
+	utils.AssertEqual(t, `{"error":null,"bytesSent":6}`+"\n", jsonBuf.String())
+}
+
+// go test -run Test_Logger_Tags
+func Test_Logger_Tags(t *testing.T) {
+	app := fiber.New()
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app.Use(New(Config{
+		Format: "${resBody}|${reqHeaders}|${queryParams}|${locals:user}|${locals:missing}",
+		Output: buf,
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("user", "john")
+		return c.SendString("hello")
+	})
+
+	req := httptest.NewRequest("GET", "/?a=1&b=2", nil)
+	req.Header = http.Header{"X-Custom": []string{"value"}}
+	_, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "hello|Host=example.com&X-Custom=value|a=1&b=2|john|-", buf.String())
+}
+
+// go test -run Test_Logger_Concurrent_Latency
+func Test_Logger_Concurrent_Latency(t *testing.T) {
+	app := fiber.New()
+
+	var mu sync.Mutex
+	latencies := make(map[string]time.Duration)
+	app.Use(New(Config{
+		Format: "${path} ${latency}\n",
+		Output: writerFunc(func(p []byte) (int, error) {
+			fields := strings.Fields(string(p))
+			d, err := time.ParseDuration(fields[1])
+			utils.AssertEqual(t, nil, err)
+			mu.Lock()
+			latencies[fields[0]] = d
+			mu.Unlock()
+			return len(p), nil
+		}),
+	}))
+
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"/slow", "/fast"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			_, err := app.Test(httptest.NewRequest("GET", path, nil))
+			utils.AssertEqual(t, nil, err)
+		}(path)
+	}
+	wg.Wait()
+
+	utils.AssertEqual(t, true, latencies["/slow"] >= 100*time.Millisecond)
+	utils.AssertEqual(t, true, latencies["/fast"] < 100*time.Millisecond)
+}
+
+// go test -run Test_Logger_Colors
+func Test_Logger_Colors(t *testing.T) {
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	// Not a terminal
+	utils.AssertEqual(t, false, isTerminal(buf))
+
+	cfg := Config{enableColors: true}
+	d := &data{}
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	c.Status(fiber.StatusNotFound)
+
+	_, err := writeTag(buf, c, &cfg, d, TagStatus)
+	utils.AssertEqual(t, nil, err)
+	_, err = writeTag(buf, c, &cfg, d, TagMethod)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, cYellow+"404"+cReset+cCyan+"GET"+cReset, buf.String())
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// go test -run Test_Logger_Async
+func Test_Logger_Async(t *testing.T) {
+	buf := &syncBuffer{}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(New(Config{
+		Format:        "${path}\n",
+		Output:        buf,
+		Async:         true,
+		FlushInterval: time.Hour,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	go func() {
+		_ = app.Listener(ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, resp.Body.Close())
+
+	// Buffered until the app is shut down
+	utils.AssertEqual(t, "", buf.String())
+	utils.AssertEqual(t, nil, app.Shutdown())
+	utils.AssertEqual(t, "/\n", buf.String())
+}
+
+// go test -run Test_Logger_Async_Flush_Interval
+func Test_Logger_Async_Flush_Interval(t *testing.T) {
+	buf := &syncBuffer{}
+	w := newAsyncWriter(buf, 10, false, 10*time.Millisecond)
+
+	_, err := w.Write([]byte("log\n"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(50 * time.Millisecond)
+	utils.AssertEqual(t, "log\n", buf.String())
+}
+
+// go test -run Test_Logger_Async_Close
+func Test_Logger_Async_Close(t *testing.T) {
+	buf := &syncBuffer{}
+	w := newAsyncWriter(buf, 10, false, time.Hour)
+
+	_, err := w.Write([]byte("queued\n"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, w.Close())
+	utils.AssertEqual(t, "queued\n", buf.String())
+
+	// Written directly once the goroutine is stopped
+	_, err = w.Write([]byte("direct\n"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "queued\ndirect\n", buf.String())
+	w.Flush()
+	utils.AssertEqual(t, nil, w.Close())
+}
+
+// go test -run Test_Logger_Async_Close_Full_Queue -race
+func Test_Logger_Async_Close_Full_Queue(t *testing.T) {
+	buf := &syncBuffer{}
+	w := newAsyncWriter(buf, 1, false, time.Hour)
+
+	// Close while the writers wait for room in the queue
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := w.Write([]byte("c"))
+				utils.AssertEqual(t, nil, err)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	utils.AssertEqual(t, nil, w.Close())
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked after close")
+	}
+
+	// No line is lost
+	utils.AssertEqual(t, 5000, len(buf.String()))
+}
+
+// go test -run Test_Logger_Async_Drop
+func Test_Logger_Async_Drop(t *testing.T) {
+	buf := &syncBuffer{}
+	blocked := make(chan struct{})
+	w := newAsyncWriter(writerFunc(func(p []byte) (int, error) {
+		<-blocked
+		return buf.Write(p)
+	}), 1, true, time.Hour)
+
+	// Fill the buffer, so the writer blocks on the next flush
+	_, _ = w.Write(bytes.Repeat([]byte("a"), 4096))
+	_, _ = w.Write([]byte("b"))
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("c"))
+		utils.AssertEqual(t, nil, err)
+	}
+	close(blocked)
+	w.Flush()
+
+	out := buf.String()
+	utils.AssertEqual(t, true, strings.Contains(out, "logger: dropped"))
+	utils.AssertEqual(t, true, strings.Count(out, "c") < 3)
+}
+
+// go test -run Test_Logger_Write_Error
+func Test_Logger_Write_Error(t *testing.T) {
+	var errs []string
+	app := fiber.New()
+	app.Use(New(Config{
+		Output: writerFunc(func(p []byte) (int, error) {
+			errs = append(errs, string(p))
+			return 0, errors.New("write failed")
+		}),
+	}))
+
+	_, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 2, len(errs))
+	utils.AssertEqual(t, "write failed\n", errs[1])
+}
+
+// go test -run Test_RotatingFile
+func Test_RotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	utils.AssertEqual(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "logs", "access.log")
+	f, err := NewRotatingFile(RotateConfig{
+		Filename:   filename,
+		MaxSize:    10,
+		MaxBackups: 2,
+	})
+	utils.AssertEqual(t, nil, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = f.Write([]byte(line))
+		utils.AssertEqual(t, nil, err)
+	}
+	utils.AssertEqual(t, nil, f.Close())
+
+	content, err := ioutil.ReadFile(filename)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "fourth\n", string(content))
+
+	backups, err := filepath.Glob(filepath.Join(dir, "logs", "access-*.log"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 2, len(backups))
+	content, err = ioutil.ReadFile(backups[1])
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "third\n", string(content))
+
+	_, err = f.Write([]byte("closed"))
+	utils.AssertEqual(t, os.ErrClosed, err)
+}
+
+// go test -run Test_RotatingFile_Rotate_Error
+func Test_RotatingFile_Rotate_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	utils.AssertEqual(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "access.log")
+	f, err := NewRotatingFile(RotateConfig{
+		Filename: filename,
+		MaxSize:  10,
+	})
+	utils.AssertEqual(t, nil, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("first\n"))
+	utils.AssertEqual(t, nil, err)
+
+	// The rename fails, the line is still written to the open file
+	utils.AssertEqual(t, nil, os.Remove(filename))
+	n, err := f.Write([]byte("second\n"))
+	utils.AssertEqual(t, true, os.IsNotExist(err))
+	utils.AssertEqual(t, len("second\n"), n)
+
+	// The rotation is retried on the next write
+	utils.AssertEqual(t, nil, ioutil.WriteFile(filename, nil, 0644))
+	_, err = f.Write([]byte("third\n"))
+	utils.AssertEqual(t, nil, err)
+
+	content, err := ioutil.ReadFile(filename)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "third\n", string(content))
+}
+
+// go test -run Test_RotatingFile_Interval
+func Test_RotatingFile_Interval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	utils.AssertEqual(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "access.log")
+	f, err := NewRotatingFile(RotateConfig{
+		Filename: filename,
+		Interval: 20 * time.Millisecond,
+	})
+	utils.AssertEqual(t, nil, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("first\n"))
+	utils.AssertEqual(t, nil, err)
+	_, err = f.Write([]byte("second\n"))
+	utils.AssertEqual(t, nil, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = f.Write([]byte("third\n"))
+	utils.AssertEqual(t, nil, err)
+
+	content, err := ioutil.ReadFile(filename)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "third\n", string(content))
+
+	backups, err := filepath.Glob(filepath.Join(dir, "access-*.log"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 1, len(backups))
+	content, err = ioutil.ReadFile(backups[0])
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "first\nsecond\n", string(content))
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
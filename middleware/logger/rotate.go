@@ -0,0 +1,244 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateConfig defines the config for RotatingFile.
+type RotateConfig struct {
+	// Filename is the file the logs are written to. Rotated files are
+	// renamed to <name>-<timestamp><ext> in the same directory.
+	//
+	// Required.
+	Filename string
+
+	// MaxSize is the size in bytes after which the file is rotated, a
+	// negative value disables rotation by size.
+	//
+	// Optional. Default: 100 MB
+	MaxSize int64
+
+	// Interval rotates the file when it has been written to for the
+	// duration, zero disables rotation by time.
+	//
+	// Optional. Default: 0
+	Interval time.Duration
+
+	// MaxBackups is the maximum number of rotated files to keep, zero keeps
+	// all of them.
+	//
+	// Optional. Default: 0
+	MaxBackups int
+
+	// MaxAge is the maximum age of rotated files to keep, zero keeps them
+	// regardless of their age.
+	//
+	// Optional. Default: 0
+	MaxAge time.Duration
+
+	// Perm is the permission of the created files.
+	//
+	// Optional. Default: 0644
+	Perm os.FileMode
+}
+
+// RotateConfigDefault is the default config
+var RotateConfigDefault = RotateConfig{
+	MaxSize: 100 << 20,
+	Perm:    0644,
+}
+
+// backupTimeFormat sorts lexically and is valid in file names
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is an io.WriteCloser that writes to a file and rotates it by
+// size and time, it can be used as the Output of the middleware.
+type RotatingFile struct {
+	cfg    RotateConfig
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens the file of the config for appending, the
+// directory is created if it doesn't exist.
+func NewRotatingFile(config RotateConfig) (*RotatingFile, error) {
+	cfg := config
+	if cfg.Filename == "" {
+		return nil, fmt.Errorf("logger: rotating file requires a Filename")
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = RotateConfigDefault.MaxSize
+	}
+	if cfg.Perm == 0 {
+		cfg.Perm = RotateConfigDefault.Perm
+	}
+
+	f := &RotatingFile{cfg: cfg}
+	if err := os.MkdirAll(filepath.Dir(cfg.Filename), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes p to the file, the file is rotated before if p doesn't fit
+// in MaxSize or the Interval has passed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	// A failed rotation is retried on the next write, p is written to the
+	// current file and the error returned
+	var rotateErr error
+	if f.shouldRotate(int64(len(p))) {
+		rotateErr = f.rotate()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate renames the current file to a backup and opens a new file, the
+// current file is kept open if that fails.
+func (f *RotatingFile) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) shouldRotate(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.cfg.MaxSize > 0 && f.size+n > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.Interval > 0 && time.Since(f.opened) >= f.cfg.Interval
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, f.cfg.Perm)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	// Don't overwrite a backup of the same millisecond
+	now := time.Now()
+	backup := f.backupName(now)
+	for {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Millisecond)
+		backup = f.backupName(now)
+	}
+	// Rename the file while it is open, so it's still written to if the
+	// rename or opening the new file fails
+	old := f.file
+	if err := os.Rename(f.cfg.Filename, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		return err
+	}
+	return f.removeBackups()
+}
+
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.cfg.Filename)
+	return strings.TrimSuffix(f.cfg.Filename, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// removeBackups removes the backups exceeding MaxBackups or MaxAge
+func (f *RotatingFile) removeBackups() error {
+	if f.cfg.MaxBackups <= 0 && f.cfg.MaxAge <= 0 {
+		return nil
+	}
+	dir := filepath.Dir(f.cfg.Filename)
+	ext := filepath.Ext(f.cfg.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.cfg.Filename), ext) + "-"
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type backup struct {
+		name string
+		time time.Time
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, name[len(prefix):len(name)-len(ext)], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name: name, time: t})
+	}
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+
+	var firstErr error
+	for i, b := range backups {
+		expired := f.cfg.MaxAge > 0 && time.Since(b.time) > f.cfg.MaxAge
+		if (f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups) || expired {
+			if err := os.Remove(filepath.Join(dir, b.name)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2/internal/bytebufferpool"
+)
+
+// asyncWriter writes to an io.Writer from a separate goroutine through a
+// bounded queue and a buffer that is flushed periodically
+type asyncWriter struct {
+	out     io.Writer
+	buf     *bufio.Writer
+	queue   chan *bytebufferpool.ByteBuffer
+	flush   chan chan struct{}
+	drop    bool
+	dropped uint64
+	done    chan struct{} // Closed by Close to stop the goroutine
+	stopped chan struct{} // Closed when the goroutine has stopped
+	close   sync.Once
+	mutex   sync.RWMutex // Held for reading while a line is queued
+	closed  bool         // Set by Close, lines aren't queued anymore
+}
+
+func newAsyncWriter(out io.Writer, queueSize int, drop bool, interval time.Duration) *asyncWriter {
+	w := &asyncWriter{
+		out:     out,
+		buf:     bufio.NewWriter(out),
+		queue:   make(chan *bytebufferpool.ByteBuffer, queueSize),
+		flush:   make(chan chan struct{}),
+		drop:    drop,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run(interval)
+	return w
+}
+
+// Write queues a copy of p, it blocks until there is room in the queue
+// unless drop is set, then p is discarded if the queue is full.
+// After Close p is written to the output directly.
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mutex.RLock()
+	if w.closed {
+		w.mutex.RUnlock()
+		<-w.stopped
+		return w.out.Write(p)
+	}
+	defer w.mutex.RUnlock()
+
+	b := bytebufferpool.Get()
+	_, _ = b.Write(p)
+	if !w.drop {
+		w.queue <- b
+		return len(p), nil
+	}
+	select {
+	case w.queue <- b:
+	default:
+		bytebufferpool.Put(b)
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Flush writes all queued logs to the output and waits until it's done
+func (w *asyncWriter) Flush() {
+	done := make(chan struct{})
+	select {
+	case w.flush <- done:
+		<-done
+	case <-w.stopped:
+	}
+}
+
+// Close writes all queued logs to the output and stops the goroutine
+func (w *asyncWriter) Close() error {
+	w.close.Do(func() {
+		// Wait for the writes blocked on a full queue, the goroutine keeps
+		// taking lines from the queue until done is closed
+		w.mutex.Lock()
+		w.closed = true
+		w.mutex.Unlock()
+		close(w.done)
+	})
+	<-w.stopped
+	return nil
+}
+
+func (w *asyncWriter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			w.drain()
+			w.flushBuffer()
+			close(w.stopped)
+			return
+		case b := <-w.queue:
+			w.write(b)
+		case <-ticker.C:
+			w.flushBuffer()
+		case done := <-w.flush:
+			w.drain()
+			w.flushBuffer()
+			close(done)
+		}
+	}
+}
+
+func (w *asyncWriter) drain() {
+	for {
+		select {
+		case b := <-w.queue:
+			w.write(b)
+		default:
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) write(b *bytebufferpool.ByteBuffer) {
+	if _, err := w.buf.Write(b.Bytes()); err != nil {
+		w.fail(err)
+	}
+	bytebufferpool.Put(b)
+}
+
+func (w *asyncWriter) flushBuffer() {
+	if dropped := atomic.SwapUint64(&w.dropped, 0); dropped > 0 {
+		_, _ = fmt.Fprintf(w.buf, "logger: dropped %d log lines, the queue was full\n", dropped)
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.fail(err)
+	}
+}
+
+// fail reports err and discards the buffer, a bufio.Writer doesn't accept
+// writes anymore after an error
+func (w *asyncWriter) fail(err error) {
+	w.buf.Reset(w.out)
+	writeError(w.out, err)
+}
+
+// writeError writes err to the output, or to stderr if the output fails too
+func writeError(out io.Writer, err error) {
+	if _, werr := out.Write([]byte(err.Error() + "\n")); werr != nil && out != os.Stderr {
+		_, _ = fmt.Fprintf(os.Stderr, "logger: %v\n", err)
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Load balancing algorithms
+const (
+	roundRobin       = "round-robin"
+	leastConnections = "least-connections"
+	consistentHash   = "consistent-hash"
+)
+
+// Virtual nodes per upstream on the consistent hash ring
+const replicas = 100
+
+// upstream is a single upstream server
+type upstream struct {
+	addr   string
+	client *fasthttp.HostClient
+	// requests in flight
+	active int64
+	// consecutive failed requests
+	fails int64
+	// unix time in nanoseconds until which the upstream is ejected
+	ejected int64
+	// one token per open streaming connection, limited to MaxConnsPerHost
+	conns chan struct{}
+	// idle streaming connections, least recently used first
+	mu   sync.Mutex
+	idle []*streamConn
+}
+
+func (u *upstream) healthy(now int64) bool {
+	return atomic.LoadInt64(&u.ejected) <= now
+}
+
+// success resets the failure count and brings back an ejected upstream
+func (u *upstream) success() {
+	atomic.StoreInt64(&u.fails, 0)
+	atomic.StoreInt64(&u.ejected, 0)
+}
+
+func (u *upstream) eject(d time.Duration) {
+	atomic.StoreInt64(&u.ejected, time.Now().Add(d).UnixNano())
+}
+
+// balancer selects the upstream of a request
+type balancer struct {
+	cfg       Config
+	upstreams []*upstream
+	counter   uint64
+	// consistent hash ring, sorted hashes and the upstream of each hash
+	ring  []uint32
+	nodes map[uint32]*upstream
+}
+
+func newBalancer(cfg Config, upstreams []*upstream) *balancer {
+	lb := &balancer{
+		cfg:       cfg,
+		upstreams: upstreams,
+	}
+	if cfg.LoadBalancing == consistentHash {
+		lb.nodes = make(map[uint32]*upstream, len(upstreams)*replicas)
+		for _, u := range upstreams {
+			for i := 0; i < replicas; i++ {
+				h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + u.addr))
+				if _, ok := lb.nodes[h]; !ok {
+					lb.nodes[h] = u
+					lb.ring = append(lb.ring, h)
+				}
+			}
+		}
+		sort.Slice(lb.ring, func(i, j int) bool { return lb.ring[i] < lb.ring[j] })
+	}
+	return lb
+}
+
+// do forwards the request to an upstream, failed requests are retried on
+// the upstreams that were not tried yet
+func (lb *balancer) do(c *fiber.Ctx, retries int) (err error) {
+	req, res := c.Request(), c.Response()
+	tried := make(map[*upstream]bool, retries+1)
+	for attempt := 0; attempt <= retries && len(tried) < len(lb.upstreams); attempt++ {
+		u := lb.next(c, tried)
+		tried[u] = true
+
+		atomic.AddInt64(&u.active, 1)
+		if lb.cfg.StreamResponseBody {
+			err = u.stream(req, res, lb.cfg.Timeout)
+		} else if lb.cfg.Timeout > 0 {
+			err = u.client.DoTimeout(req, res, lb.cfg.Timeout)
+		} else {
+			err = u.client.Do(req, res)
+		}
+		atomic.AddInt64(&u.active, -1)
+
+		// Passive health check, 5xx responses are passed on to the client
+		if lb.cfg.MaxFails > 0 {
+			if err == nil && res.StatusCode() < fiber.StatusInternalServerError {
+				u.success()
+			} else if atomic.AddInt64(&u.fails, 1) >= int64(lb.cfg.MaxFails) {
+				u.eject(lb.cfg.FailTimeout)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		res.Reset()
+	}
+	return err
+}
+
+// next selects an upstream that was not tried yet, ejected upstreams are
+// only used when all upstreams are ejected
+func (lb *balancer) next(c *fiber.Ctx, tried map[*upstream]bool) *upstream {
+	now := time.Now().UnixNano()
+	usable := func(u *upstream) bool {
+		return !tried[u] && u.healthy(now)
+	}
+	if u := lb.pick(c, usable); u != nil {
+		return u
+	}
+	return lb.pick(c, func(u *upstream) bool {
+		return !tried[u]
+	})
+}
+
+func (lb *balancer) pick(c *fiber.Ctx, usable func(u *upstream) bool) *upstream {
+	switch lb.cfg.LoadBalancing {
+	case leastConnections:
+		var best *upstream
+		// Start at a rotating offset so ties are spread evenly
+		offset := int(atomic.AddUint64(&lb.counter, 1))
+		for i := range lb.upstreams {
+			u := lb.upstreams[(offset+i)%len(lb.upstreams)]
+			if usable(u) && (best == nil || atomic.LoadInt64(&u.active) < atomic.LoadInt64(&best.active)) {
+				best = u
+			}
+		}
+		return best
+	case consistentHash:
+		h := crc32.ChecksumIEEE([]byte(lb.cfg.HashKey(c)))
+		start := sort.Search(len(lb.ring), func(i int) bool { return lb.ring[i] >= h })
+		for i := range lb.ring {
+			if u := lb.nodes[lb.ring[(start+i)%len(lb.ring)]]; usable(u) {
+				return u
+			}
+		}
+		return nil
+	default:
+		offset := int(atomic.AddUint64(&lb.counter, 1) - 1)
+		for i := range lb.upstreams {
+			if u := lb.upstreams[(offset+i)%len(lb.upstreams)]; usable(u) {
+				return u
+			}
+		}
+		return nil
+	}
+}
+
+// healthCheck requests the HealthCheckPath of every upstream each
+// HealthCheckInterval until ctx is done
+func (lb *balancer) healthCheck(ctx context.Context) {
+	ticker := time.NewTicker(lb.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		for _, u := range lb.upstreams {
+			lb.check(u)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lb *balancer) check(u *upstream) {
+	req := fasthttp.AcquireRequest()
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(res)
+
+	scheme := "http://"
+	if lb.cfg.IsTLS {
+		scheme = "https://"
+	}
+	req.SetRequestURI(scheme + u.addr + lb.cfg.HealthCheckPath)
+	req.Header.SetMethod(fiber.MethodGet)
+
+	timeout := lb.cfg.Timeout
+	if timeout <= 0 || timeout > lb.cfg.HealthCheckInterval {
+		timeout = lb.cfg.HealthCheckInterval
+	}
+	err := u.client.DoTimeout(req, res, timeout)
+	if err == nil && res.StatusCode() >= fiber.StatusOK && res.StatusCode() < fiber.StatusBadRequest {
+		u.success()
+		return
+	}
+	// Ejected until the next successful check
+	u.eject(lb.cfg.HealthCheckInterval * 2)
+}
@@ -1,7 +1,15 @@
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/valyala/fasthttp"
 )
 
@@ -13,7 +21,7 @@ type Config struct {
 	Next func(c *fiber.Ctx) bool
 
 	// Comma-separated list of upstream HTTP server host addresses,
-	// which are passed to Dial in a round-robin manner.
+	// which are selected with the LoadBalancing algorithm.
 	//
 	// Each address may contain port if default dialer is used.
 	// For example,
@@ -23,6 +31,100 @@ type Config struct {
 	//    - foobar.com:8080
 	Hosts string
 
+	// LoadBalancing is the algorithm used to select an upstream for a request.
+	// Possible values:
+	// - "round-robin"
+	// - "least-connections"
+	// - "consistent-hash", requests with the same HashKey go to the same upstream
+	//
+	// Optional. Default: "round-robin"
+	LoadBalancing string
+
+	// HashKey returns the key used by the "consistent-hash" load balancing.
+	//
+	// Optional. Default: func(c *fiber.Ctx) string {
+	//   return c.IP()
+	// }
+	HashKey func(c *fiber.Ctx) string
+
+	// Timeout is the maximum duration of a request to an upstream.
+	//
+	// Optional. Default: 0, no timeout
+	Timeout time.Duration
+
+	// Retries is the number of other upstreams that are tried when an
+	// idempotent request fails with a connection error or a timeout.
+	//
+	// Optional. Default: 0
+	Retries int
+
+	// MaxFails is the number of consecutive failed requests after which an
+	// upstream is ejected for FailTimeout, a request fails on a connection
+	// error, a timeout or a 5xx response. A negative value disables the
+	// passive health check.
+	//
+	// Optional. Default: 3
+	MaxFails int
+
+	// FailTimeout is the duration an unhealthy upstream is ejected.
+	//
+	// Optional. Default: 10 * time.Second
+	FailTimeout time.Duration
+
+	// HealthCheckPath enables active health checks, the path is requested
+	// on every upstream each HealthCheckInterval and an upstream is ejected
+	// until a check returns a 2xx or 3xx status.
+	//
+	// Optional. Default: ""
+	HealthCheckPath string
+
+	// HealthCheckInterval is the time between two active health checks.
+	//
+	// Optional. Default: 10 * time.Second
+	HealthCheckInterval time.Duration
+
+	// Context stops the active health checks when it is done, they are
+	// also stopped when the app is shut down.
+	//
+	// Optional. Default: context.Background()
+	Context context.Context
+
+	// Rewrite allows you to change the path of the upstream request.
+	//
+	// Optional. Default: nil
+	Rewrite func(path string) string
+
+	// IsTLS connects to the upstreams over TLS.
+	//
+	// Optional. Default: false
+	IsTLS bool
+
+	// TLSConfig is the TLS configuration used for the upstream connections.
+	//
+	// Optional. Default: nil
+	TLSConfig *tls.Config
+
+	// MaxConnsPerHost is the maximum number of connections to an upstream,
+	// requests fail with fasthttp.ErrNoFreeConns when all are busy.
+	//
+	// Optional. Default: 512
+	MaxConnsPerHost int
+
+	// StreamResponseBody streams the upstream response body to the client
+	// instead of buffering it in memory. Timeout only applies until the
+	// response headers are read.
+	//
+	// Optional. Default: false
+	StreamResponseBody bool
+
+	// TrustedProxies are the IP addresses or CIDR ranges of the proxies in
+	// front of this one. Their X-Forwarded-* and Forwarded headers are
+	// passed on, for other clients the headers are replaced with the values
+	// observed by this proxy.
+	//
+	// Optional. Default: nil
+	TrustedProxies []string
+
 	// Before allows you to alter the request
 	Before fiber.Handler
 
@@ -32,7 +134,16 @@ type Config struct {
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next: nil,
+	Next:          nil,
+	LoadBalancing: roundRobin,
+	HashKey: func(c *fiber.Ctx) string {
+		return c.IP()
+	},
+	MaxFails:            3,
+	FailTimeout:         10 * time.Second,
+	HealthCheckInterval: 10 * time.Second,
+	Context:             context.Background(),
+	MaxConnsPerHost:     fasthttp.DefaultMaxConnsPerHost,
 }
 
 // New creates a new middleware handler
@@ -44,17 +155,67 @@ func New(config Config) fiber.Handler {
 	if cfg.Next == nil {
 		cfg.Next = ConfigDefault.Next
 	}
+	if cfg.LoadBalancing == "" {
+		cfg.LoadBalancing = ConfigDefault.LoadBalancing
+	}
+	if cfg.HashKey == nil {
+		cfg.HashKey = ConfigDefault.HashKey
+	}
+	if cfg.MaxFails == 0 {
+		cfg.MaxFails = ConfigDefault.MaxFails
+	}
+	if cfg.FailTimeout <= 0 {
+		cfg.FailTimeout = ConfigDefault.FailTimeout
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = ConfigDefault.HealthCheckInterval
+	}
+	if cfg.Context == nil {
+		cfg.Context = ConfigDefault.Context
+	}
+	if cfg.MaxConnsPerHost <= 0 {
+		cfg.MaxConnsPerHost = ConfigDefault.MaxConnsPerHost
+	}
 	if cfg.Hosts == "" {
 		return func(c *fiber.Ctx) error {
 			return c.Next()
 		}
 	}
+	trusted := parseTrustedProxies(cfg.TrustedProxies)
 
-	// Create host client
+	// Create a host client for every upstream
 	// https://godoc.org/github.com/valyala/fasthttp#HostClient
-	hostClient := fasthttp.HostClient{
-		Addr:                     cfg.Hosts,
-		NoDefaultUserAgentHeader: true,
+	var upstreams []*upstream
+	for _, host := range strings.Split(cfg.Hosts, ",") {
+		if host = utils.Trim(host, ' '); host != "" {
+			upstreams = append(upstreams, &upstream{
+				addr: host,
+				client: &fasthttp.HostClient{
+					Addr:                     host,
+					NoDefaultUserAgentHeader: true,
+					DisablePathNormalizing:   true,
+					IsTLS:                    cfg.IsTLS,
+					TLSConfig:                cfg.TLSConfig,
+					MaxConns:                 cfg.MaxConnsPerHost,
+				},
+				conns: make(chan struct{}, cfg.MaxConnsPerHost),
+			})
+		}
+	}
+	lb := newBalancer(cfg, upstreams)
+
+	// Stop the health checks and close the idle streaming connections
+	// when the app is shut down
+	ctx, cancel := context.WithCancel(cfg.Context)
+	if cfg.HealthCheckPath != "" {
+		go lb.healthCheck(ctx)
+	}
+	var onShutdown sync.Once
+	shutdown := func() {
+		cancel()
+		for _, u := range upstreams {
+			u.closeIdle()
+		}
 	}
 
 	// Return new handler
@@ -63,13 +224,27 @@ func New(config Config) fiber.Handler {
 		if cfg.Next != nil && cfg.Next(c) {
 			return c.Next()
 		}
+		onShutdown.Do(func() {
+			c.App().OnShutdown(shutdown)
+		})
 
 		// Set request and response
 		req := c.Request()
 		res := c.Response()
 
-		// Don't proxy "Connection" header
-		req.Header.Del(fiber.HeaderConnection)
+		// Don't proxy hop-by-hop headers and tell the upstream who the client is
+		stripHopByHop(&req.Header)
+		setForwarded(c, trusted)
+
+		// Rewrite the upstream path
+		if cfg.Rewrite != nil {
+			req.URI().SetPath(cfg.Rewrite(c.Path()))
+		}
+		if cfg.IsTLS {
+			req.URI().SetScheme("https")
+		} else {
+			req.URI().SetScheme("http")
+		}
 
 		// Modify request
 		if cfg.Before != nil {
@@ -78,13 +253,17 @@ func New(config Config) fiber.Handler {
 			}
 		}
 
-		// Forward request
-		if err = hostClient.Do(req, res); err != nil {
+		// Forward request, retrying idempotent requests on other upstreams
+		retries := 0
+		if isIdempotent(&req.Header) {
+			retries = cfg.Retries
+		}
+		if err = lb.do(c, retries); err != nil {
 			return err
 		}
 
-		// Don't proxy "Connection" header
-		res.Header.Del(fiber.HeaderConnection)
+		// Don't proxy hop-by-hop headers
+		stripHopByHop(&res.Header)
 
 		// Modify response
 		if cfg.After != nil {
@@ -97,3 +276,169 @@ func New(config Config) fiber.Handler {
 		return nil
 	}
 }
+
+var client = fasthttp.Client{
+	NoDefaultUserAgentHeader: true,
+	DisablePathNormalizing:   true,
+}
+
+// Do forwards the request to the given url and writes the upstream
+// response to c, the url must contain the scheme and host.
+func Do(c *fiber.Ctx, url string) error {
+	req := c.Request()
+	res := c.Response()
+
+	// Restore the original host and url for the handlers after the proxy
+	originalHost := utils.ImmutableString(c.Hostname())
+	originalURL := utils.ImmutableString(c.OriginalURL())
+	defer func() {
+		req.Header.SetHost(originalHost)
+		req.SetRequestURI(originalURL)
+	}()
+
+	stripHopByHop(&req.Header)
+	setForwarded(c, nil)
+
+	req.SetRequestURI(url)
+	if err := client.Do(req, res); err != nil {
+		return err
+	}
+
+	stripHopByHop(&res.Header)
+	return nil
+}
+
+func isIdempotent(h *fasthttp.RequestHeader) bool {
+	return h.IsGet() || h.IsHead() || h.IsPut() || h.IsDelete() || h.IsOptions() || h.IsTrace()
+}
+
+// hopByHop are the headers that only apply to a single connection
+// https://datatracker.ietf.org/doc/html/rfc7230#section-6.1
+var hopByHop = []string{
+	fiber.HeaderConnection,
+	fiber.HeaderKeepAlive,
+	"Proxy-Connection",
+	fiber.HeaderProxyAuthenticate,
+	fiber.HeaderProxyAuthorization,
+	fiber.HeaderTE,
+	fiber.HeaderTrailer,
+	fiber.HeaderTransferEncoding,
+	fiber.HeaderUpgrade,
+}
+
+type header interface {
+	Peek(key string) []byte
+	Del(key string)
+}
+
+// stripHopByHop removes the hop-by-hop headers and the headers listed
+// in the Connection header
+func stripHopByHop(h header) {
+	if connection := h.Peek(fiber.HeaderConnection); len(connection) > 0 {
+		for _, name := range strings.Split(string(connection), ",") {
+			if name = utils.Trim(name, ' '); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHop {
+		h.Del(name)
+	}
+}
+
+// setForwarded adds the client to the X-Forwarded-* and Forwarded headers,
+// the headers sent by the client are only passed on if it is a trusted proxy
+func setForwarded(c *fiber.Ctx, trusted []*net.IPNet) {
+	req := c.Request()
+	remoteIP := c.Context().RemoteIP()
+	ip := remoteIP.String()
+	proto := "http"
+	if c.Context().IsTLS() {
+		proto = "https"
+	}
+	host := utils.ImmutableString(c.Hostname())
+
+	// https://datatracker.ietf.org/doc/html/rfc7239#section-4
+	node := ip
+	if strings.IndexByte(ip, ':') >= 0 {
+		node = `"[` + ip + `]"`
+	}
+	forwardedFor := ip
+	forwarded := "for=" + node + ";host=" + quoteString(host) + ";proto=" + proto
+
+	if isTrusted(remoteIP, trusted) {
+		if prior := c.Get(fiber.HeaderXForwardedFor); prior != "" {
+			forwardedFor = prior + ", " + forwardedFor
+		}
+		if prior := c.Get(fiber.HeaderForwarded); prior != "" {
+			forwarded = prior + ", " + forwarded
+		}
+		if prior := c.Get(fiber.HeaderXForwardedProto); prior != "" {
+			proto = utils.ImmutableString(prior)
+		}
+		if prior := c.Get(fiber.HeaderXForwardedHost); prior != "" {
+			host = utils.ImmutableString(prior)
+		}
+	}
+
+	req.Header.Set(fiber.HeaderXForwardedFor, forwardedFor)
+	req.Header.Set(fiber.HeaderXForwardedProto, proto)
+	req.Header.Set(fiber.HeaderXForwardedHost, host)
+	req.Header.Set(fiber.HeaderForwarded, forwarded)
+}
+
+// quoteString returns s as a quoted-string
+// https://datatracker.ietf.org/doc/html/rfc7230#section-3.2.6
+func quoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseTrustedProxies parses IP addresses and CIDR ranges
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic("proxy: invalid trusted proxy " + proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addMissingPort adds the default port of the scheme to addr
+func addMissingPort(addr string, isTLS bool) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	if isTLS {
+		return addr + ":443"
+	}
+	return addr + ":80"
+}
@@ -1,15 +1,22 @@
 package proxy
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
 )
 
 // go test -run Test_Proxy_Empty_Host
@@ -168,5 +175,573 @@ func Test_Proxy_Do_With_Error(t *testing.T) {
 
 	b, err := ioutil.ReadAll(resp.Body)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, strings.Contains(string(b), "127.0.0.1:90000"))
+	utils.AssertEqual(t, true, strings.Contains(string(b), "90000"))
+}
+
+// listen starts the app on a random port and returns its address
+func listen(t *testing.T, app *fiber.App, isTLS bool) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+
+	if isTLS {
+		cer, err := tls.LoadX509KeyPair("../../.github/testdata/ssl.pem", "../../.github/testdata/ssl.key")
+		utils.AssertEqual(t, nil, err)
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cer}})
+	}
+
+	go func() {
+		_ = app.Listener(ln)
+	}()
+
+	return ln.Addr().String()
+}
+
+// startUpstream starts an app on a random port and returns its address
+func startUpstream(t *testing.T, name string, health int) (string, *fiber.App) {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendStatus(health)
+	})
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendString(name + ":" + c.Path())
+	})
+
+	return listen(t, app, false), app
+}
+
+// closedAddr returns an address nobody listens on
+func closedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	addr := ln.Addr().String()
+	utils.AssertEqual(t, nil, ln.Close())
+	return addr
+}
+
+func proxyRequest(t *testing.T, app *fiber.App, method, path string) (int, string) {
+	resp, err := app.Test(httptest.NewRequest(method, path, nil))
+	utils.AssertEqual(t, nil, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	return resp.StatusCode, string(b)
+}
+
+// go test -run Test_Proxy_Round_Robin
+func Test_Proxy_Round_Robin(t *testing.T) {
+	addr1, upstream1 := startUpstream(t, "one", fiber.StatusOK)
+	defer upstream1.Shutdown()
+	addr2, upstream2 := startUpstream(t, "two", fiber.StatusOK)
+	defer upstream2.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts: addr1 + ", " + addr2,
+		Rewrite: func(path string) string {
+			return strings.TrimPrefix(path, "/api")
+		},
+	}))
+
+	_, first := proxyRequest(t, app, "GET", "/api/users")
+	_, second := proxyRequest(t, app, "GET", "/api/users")
+	_, third := proxyRequest(t, app, "GET", "/api/users")
+	utils.AssertEqual(t, true, first != second)
+	utils.AssertEqual(t, first, third)
+	utils.AssertEqual(t, true, strings.HasSuffix(first, ":/users"))
+}
+
+// go test -run Test_Proxy_Retries
+func Test_Proxy_Retries(t *testing.T) {
+	addr, upstream := startUpstream(t, "up", fiber.StatusOK)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:    closedAddr(t) + "," + addr,
+		Retries:  1,
+		MaxFails: -1,
+	}))
+
+	// Idempotent requests are retried on the other upstream
+	for i := 0; i < 4; i++ {
+		status, body := proxyRequest(t, app, "GET", "/")
+		utils.AssertEqual(t, fiber.StatusOK, status)
+		utils.AssertEqual(t, "up:/", body)
+	}
+
+	// Non idempotent requests are not retried
+	failed := 0
+	for i := 0; i < 4; i++ {
+		if status, _ := proxyRequest(t, app, "POST", "/"); status != fiber.StatusOK {
+			failed++
+		}
+	}
+	utils.AssertEqual(t, 2, failed)
+}
+
+// go test -run Test_Proxy_Passive_Health_Check
+func Test_Proxy_Passive_Health_Check(t *testing.T) {
+	addr, upstream := startUpstream(t, "up", fiber.StatusOK)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:    closedAddr(t) + "," + addr,
+		MaxFails: 1,
+	}))
+
+	// The first request to the closed upstream fails and ejects it
+	failed := 0
+	for i := 0; i < 6; i++ {
+		if status, _ := proxyRequest(t, app, "GET", "/"); status != fiber.StatusOK {
+			failed++
+		}
+	}
+	utils.AssertEqual(t, 1, failed)
+}
+
+// go test -run Test_Proxy_Passive_Health_Check_Server_Error
+func Test_Proxy_Passive_Health_Check_Server_Error(t *testing.T) {
+	addr1, upstream1 := startUpstream(t, "up", fiber.StatusOK)
+	defer upstream1.Shutdown()
+
+	broken := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	broken.All("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusBadGateway)
+	})
+	addr2 := listen(t, broken, false)
+	defer broken.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:    addr1 + "," + addr2,
+		MaxFails: 2,
+	}))
+
+	// The 5xx responses are passed on until the upstream is ejected
+	failed := 0
+	for i := 0; i < 8; i++ {
+		if status, _ := proxyRequest(t, app, "GET", "/"); status == fiber.StatusBadGateway {
+			failed++
+		}
+	}
+	utils.AssertEqual(t, 2, failed)
+}
+
+// go test -run Test_Proxy_Active_Health_Check
+func Test_Proxy_Active_Health_Check(t *testing.T) {
+	addr1, upstream1 := startUpstream(t, "healthy", fiber.StatusOK)
+	defer upstream1.Shutdown()
+	addr2, upstream2 := startUpstream(t, "unhealthy", fiber.StatusServiceUnavailable)
+	defer upstream2.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:               addr1 + "," + addr2,
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 50 * time.Millisecond,
+		Context:             ctx,
+	}))
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		_, body := proxyRequest(t, app, "GET", "/")
+		utils.AssertEqual(t, "healthy:/", body)
+	}
+
+	// The health checks stop with the context
+	lb := newBalancer(Config{HealthCheckInterval: time.Hour}, nil)
+	done := make(chan struct{})
+	go func() {
+		lb.healthCheck(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("health check did not stop")
+	}
+}
+
+// go test -run Test_Proxy_Active_Health_Check_Shutdown
+func Test_Proxy_Active_Health_Check_Shutdown(t *testing.T) {
+	var checks int64
+	upstream := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	upstream.Get("/health", func(c *fiber.Ctx) error {
+		atomic.AddInt64(&checks, 1)
+		return nil
+	})
+	addr := listen(t, upstream, false)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:               addr,
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 10 * time.Millisecond,
+	}))
+	listen(t, app, false)
+
+	proxyRequest(t, app, "GET", "/")
+	time.Sleep(50 * time.Millisecond)
+	utils.AssertEqual(t, nil, app.Shutdown())
+
+	// The health checks stop with the app
+	time.Sleep(20 * time.Millisecond)
+	stopped := atomic.LoadInt64(&checks)
+	utils.AssertEqual(t, true, stopped > 0)
+	time.Sleep(50 * time.Millisecond)
+	utils.AssertEqual(t, stopped, atomic.LoadInt64(&checks))
+}
+
+// go test -run Test_Proxy_Balancer
+func Test_Proxy_Balancer(t *testing.T) {
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	newUpstreams := func() []*upstream {
+		return []*upstream{{addr: "a:80"}, {addr: "b:80"}, {addr: "c:80"}}
+	}
+	all := func(*upstream) bool { return true }
+
+	// Least connections
+	upstreams := newUpstreams()
+	upstreams[0].active, upstreams[1].active, upstreams[2].active = 3, 1, 2
+	lb := newBalancer(Config{LoadBalancing: leastConnections}, upstreams)
+	for i := 0; i < 3; i++ {
+		utils.AssertEqual(t, "b:80", lb.pick(c, all).addr)
+	}
+
+	// Consistent hashing
+	upstreams = newUpstreams()
+	key := "user-1"
+	lb = newBalancer(Config{
+		LoadBalancing: consistentHash,
+		HashKey: func(*fiber.Ctx) string {
+			return key
+		},
+	}, upstreams)
+	utils.AssertEqual(t, 3*replicas, len(lb.ring))
+	first := lb.pick(c, all)
+	for i := 0; i < 3; i++ {
+		utils.AssertEqual(t, first, lb.pick(c, all))
+	}
+	picked := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		key = "user-" + strconv.Itoa(i)
+		picked[lb.pick(c, all).addr] = true
+	}
+	utils.AssertEqual(t, 3, len(picked))
+
+	// Ejected upstreams are skipped, unless all of them are ejected
+	for _, u := range upstreams {
+		u.eject(time.Minute)
+	}
+	tried := map[*upstream]bool{}
+	utils.AssertEqual(t, true, lb.next(c, tried) != nil)
+	upstreams[1].success()
+	utils.AssertEqual(t, "b:80", lb.next(c, tried).addr)
+}
+
+// go test -run Test_Proxy_Headers
+func Test_Proxy_Headers(t *testing.T) {
+	upstream := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	upstream.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderConnection, "X-Hop")
+		c.Set("X-Hop", "1")
+		c.Set("X-End", "1")
+		return c.SendString(strings.Join([]string{
+			c.Get("X-Custom"),
+			c.Get(fiber.HeaderProxyAuthorization),
+			c.Get(fiber.HeaderXForwardedFor),
+			c.Get(fiber.HeaderXForwardedProto),
+			c.Get(fiber.HeaderXForwardedHost),
+			c.Get(fiber.HeaderForwarded),
+		}, "|"))
+	})
+	addr := listen(t, upstream, false)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts: addr,
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set(fiber.HeaderConnection, "X-Custom")
+	req.Header.Set("X-Custom", "1")
+	req.Header.Set(fiber.HeaderProxyAuthorization, "Basic Zm9vOmJhcg==")
+	req.Header.Set(fiber.HeaderXForwardedFor, "10.0.0.1")
+
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, "", resp.Header.Get("X-Hop"))
+	utils.AssertEqual(t, "1", resp.Header.Get("X-End"))
+
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `||0.0.0.0|http|example.com|for=0.0.0.0;host="example.com";proto=http`, string(b))
+
+	// Spoofed headers of a client are replaced
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set(fiber.HeaderXForwardedFor, "10.0.0.1")
+	req.Header.Set(fiber.HeaderXForwardedProto, "https")
+	req.Header.Set(fiber.HeaderXForwardedHost, "evil.com")
+	req.Header.Set(fiber.HeaderForwarded, "for=10.0.0.1")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `||0.0.0.0|http|example.com|for=0.0.0.0;host="example.com";proto=http`, string(b))
+
+	// The headers of a trusted proxy are passed on
+	app = fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:          addr,
+		TrustedProxies: []string{"10.0.0.0/8", "0.0.0.0"},
+	}))
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, `||10.0.0.1, 0.0.0.0|https|evil.com|for=10.0.0.1, for=0.0.0.0;host="example.com";proto=http`, string(b))
+}
+
+// go test -run Test_Proxy_Forwarded_Helpers
+func Test_Proxy_Forwarded_Helpers(t *testing.T) {
+	t.Parallel()
+	utils.AssertEqual(t, `"example.com"`, quoteString("example.com"))
+	utils.AssertEqual(t, `"a\"b\\c"`, quoteString(`a"b\c`))
+
+	trusted := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	utils.AssertEqual(t, true, isTrusted(net.ParseIP("10.1.2.3"), trusted))
+	utils.AssertEqual(t, true, isTrusted(net.ParseIP("192.168.1.1"), trusted))
+	utils.AssertEqual(t, false, isTrusted(net.ParseIP("192.168.1.2"), trusted))
+	utils.AssertEqual(t, true, isTrusted(net.ParseIP("::1"), trusted))
+
+	defer func() {
+		utils.AssertEqual(t, "proxy: invalid trusted proxy invalid/32", recover())
+	}()
+	parseTrustedProxies([]string{"invalid"})
+}
+
+// go test -run Test_Proxy_TLS
+func Test_Proxy_TLS(t *testing.T) {
+	upstream := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	upstream.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Protocol())
+	})
+	addr := listen(t, upstream, true)
+	defer upstream.Shutdown()
+
+	for _, stream := range []bool{false, true} {
+		app := fiber.New(fiber.Config{
+			DisableStartupMessage: true,
+		})
+		app.Use(New(Config{
+			Hosts:              addr,
+			IsTLS:              true,
+			TLSConfig:          &tls.Config{InsecureSkipVerify: true},
+			StreamResponseBody: stream,
+		}))
+
+		status, body := proxyRequest(t, app, "GET", "/")
+		utils.AssertEqual(t, fiber.StatusOK, status)
+		utils.AssertEqual(t, "https", body)
+	}
+}
+
+// go test -run Test_Proxy_Stream_Response_Body
+func Test_Proxy_Stream_Response_Body(t *testing.T) {
+	upstream := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	upstream.Get("/chunked", func(c *fiber.Ctx) error {
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			for i := 0; i < 3; i++ {
+				_, _ = w.WriteString("chunk" + strconv.Itoa(i))
+				_ = w.Flush()
+			}
+		})
+		return nil
+	})
+	upstream.Get("/length", func(c *fiber.Ctx) error {
+		return c.SendString("fixed length")
+	})
+	upstream.Get("/empty", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	addr := listen(t, upstream, false)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(New(Config{
+		Hosts:              addr,
+		StreamResponseBody: true,
+		Timeout:            time.Second,
+		After: func(c *fiber.Ctx) error {
+			c.Set("X-Stream", strconv.FormatBool(c.Response().IsBodyStream()))
+			return nil
+		},
+	}))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/chunked", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "true", resp.Header.Get("X-Stream"))
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "chunk0chunk1chunk2", string(b))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/length", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, int64(12), resp.ContentLength)
+	b, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "fixed length", string(b))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/empty", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusNoContent, resp.StatusCode)
+	utils.AssertEqual(t, "false", resp.Header.Get("X-Stream"))
+}
+
+// countingListener counts the accepted connections
+type countingListener struct {
+	net.Listener
+	accepted int64
+}
+
+func (ln *countingListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err == nil {
+		atomic.AddInt64(&ln.accepted, 1)
+	}
+	return conn, err
+}
+
+// go test -run Test_Proxy_Stream_Response_Body_Pool
+func Test_Proxy_Stream_Response_Body_Pool(t *testing.T) {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Get("/chunked", func(c *fiber.Ctx) error {
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("chunked")
+		})
+		return nil
+	})
+	app.All("/length", func(c *fiber.Ctx) error {
+		return c.SendString("fixed length")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.AssertEqual(t, nil, err)
+	counter := &countingListener{Listener: ln}
+	go func() {
+		_ = app.Listener(counter)
+	}()
+	defer app.Shutdown()
+
+	proxy := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	proxy.Use(New(Config{
+		Hosts:              ln.Addr().String(),
+		StreamResponseBody: true,
+		MaxConnsPerHost:    1,
+	}))
+
+	// Completely read bodies leave the connection open for the next request
+	for i := 0; i < 2; i++ {
+		_, body := proxyRequest(t, proxy, "GET", "/chunked")
+		utils.AssertEqual(t, "chunked", body)
+		_, body = proxyRequest(t, proxy, "GET", "/length")
+		utils.AssertEqual(t, "fixed length", body)
+	}
+	resp, err := proxy.Test(httptest.NewRequest("HEAD", "/length", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, int64(1), atomic.LoadInt64(&counter.accepted))
+
+	// The connection is busy until the body stream is closed
+	u := &upstream{addr: ln.Addr().String(), client: &fasthttp.HostClient{}, conns: make(chan struct{}, 1)}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI("http://" + u.addr + "/length")
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(res)
+	utils.AssertEqual(t, nil, u.stream(req, res, time.Second))
+	utils.AssertEqual(t, fasthttp.ErrNoFreeConns, u.stream(req, fasthttp.AcquireResponse(), time.Second))
+
+	// An unread body closes the connection
+	res.Reset()
+	utils.AssertEqual(t, 0, len(u.idle))
+	utils.AssertEqual(t, nil, u.stream(req, res, time.Second))
+	utils.AssertEqual(t, nil, res.BodyWriteTo(ioutil.Discard))
+	res.Reset()
+	utils.AssertEqual(t, 1, len(u.idle))
+	u.closeIdle()
+	utils.AssertEqual(t, 0, len(u.conns))
+}
+
+// go test -run Test_Proxy_Do
+func Test_Proxy_Do(t *testing.T) {
+	addr, upstream := startUpstream(t, "up", fiber.StatusOK)
+	defer upstream.Shutdown()
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Get("/test", func(c *fiber.Ctx) error {
+		if err := Do(c, "http://"+addr+"/target?a=b"); err != nil {
+			return err
+		}
+		c.Set("X-Path", c.OriginalURL())
+		c.Set("X-Host", c.Hostname())
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Host = "example.com"
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, "/test", resp.Header.Get("X-Path"))
+	utils.AssertEqual(t, "example.com", resp.Header.Get("X-Host"))
+	b, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "up:/target", string(b))
 }
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http/httputil"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// streamConn is a keep-alive connection to an upstream
+type streamConn struct {
+	net.Conn
+	br      *bufio.Reader
+	bw      *bufio.Writer
+	lastUse time.Time
+}
+
+// bodyStream reads the response body from the upstream connection, the
+// connection is reused if the body was read completely when fasthttp is
+// done writing it and closed otherwise
+type bodyStream struct {
+	io.Reader
+	u         *upstream
+	conn      *streamConn
+	keepAlive bool
+	// limit is set for bodies with a Content-Length
+	limit *io.LimitedReader
+	eof   bool
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	if err == io.EOF {
+		s.eof = true
+	}
+	return n, err
+}
+
+func (s *bodyStream) Close() error {
+	done := s.eof
+	if s.limit != nil {
+		done = s.limit.N == 0
+	} else if done {
+		// The chunked reader stops before the trailer
+		done = discardTrailer(s.conn.br) == nil
+	}
+	return s.u.releaseConn(s.conn, s.keepAlive && done)
+}
+
+// stream sends the request over a pooled connection and sets the upstream
+// response body as body stream of res
+func (u *upstream) stream(req *fasthttp.Request, res *fasthttp.Response, timeout time.Duration) error {
+	conn, reused, err := u.acquireConn(timeout)
+	if err != nil {
+		return err
+	}
+	err = roundTrip(conn, req, res, timeout)
+	if err != nil && reused && isIdempotent(&req.Header) {
+		// The upstream may have closed the idle connection
+		_ = u.releaseConn(conn, false)
+		if conn, err = u.newConn(timeout); err != nil {
+			return err
+		}
+		err = roundTrip(conn, req, res, timeout)
+	}
+	if err != nil {
+		_ = u.releaseConn(conn, false)
+		return err
+	}
+
+	keepAlive := !res.ConnectionClose()
+	size := res.Header.ContentLength()
+	status := res.StatusCode()
+	if req.Header.IsHead() || size == 0 || status == fiber.StatusNoContent || status == fiber.StatusNotModified {
+		// The response is complete, failing to close is not an upstream error
+		_ = u.releaseConn(conn, keepAlive)
+		return nil
+	}
+
+	body := &bodyStream{u: u, conn: conn, keepAlive: keepAlive}
+	switch size {
+	case -1:
+		// Transfer-Encoding: chunked
+		body.Reader = httputil.NewChunkedReader(conn.br)
+	case -2:
+		// Body until the connection is closed
+		body.Reader = conn.br
+		body.keepAlive = false
+		size = -1
+	default:
+		body.limit = &io.LimitedReader{R: conn.br, N: int64(size)}
+		body.Reader = body.limit
+	}
+	res.SetBodyStream(body, size)
+	return nil
+}
+
+// roundTrip writes the request and reads the response header
+func roundTrip(conn *streamConn, req *fasthttp.Request, res *fasthttp.Response, timeout time.Duration) error {
+	res.Reset()
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	err := req.Write(conn.bw)
+	if err == nil {
+		err = conn.bw.Flush()
+	}
+	if err == nil {
+		err = res.Header.Read(conn.br)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return err
+}
+
+// acquireConn returns an idle connection or dials a new one if the
+// upstream has less than MaxConnsPerHost connections
+func (u *upstream) acquireConn(timeout time.Duration) (conn *streamConn, reused bool, err error) {
+	if conn = u.idleConn(); conn != nil {
+		return conn, true, nil
+	}
+	conn, err = u.newConn(timeout)
+	return conn, false, err
+}
+
+func (u *upstream) newConn(timeout time.Duration) (*streamConn, error) {
+	select {
+	case u.conns <- struct{}{}:
+	default:
+		return nil, fasthttp.ErrNoFreeConns
+	}
+	c, err := u.dial(timeout)
+	if err != nil {
+		<-u.conns
+		return nil, err
+	}
+	return &streamConn{
+		Conn: c,
+		br:   bufio.NewReader(c),
+		bw:   bufio.NewWriter(c),
+	}, nil
+}
+
+// releaseConn puts the connection back into the idle pool or closes it
+func (u *upstream) releaseConn(conn *streamConn, reuse bool) error {
+	if reuse {
+		conn.lastUse = time.Now()
+		u.mu.Lock()
+		u.idle = append(u.idle, conn)
+		u.mu.Unlock()
+		return nil
+	}
+	err := conn.Close()
+	<-u.conns
+	return err
+}
+
+// idleConn returns the most recently used idle connection, connections
+// idle for longer than fasthttp.DefaultMaxIdleConnDuration are closed
+func (u *upstream) idleConn() *streamConn {
+	expired := time.Now().Add(-fasthttp.DefaultMaxIdleConnDuration)
+	u.mu.Lock()
+	n := 0
+	for n < len(u.idle) && u.idle[n].lastUse.Before(expired) {
+		n++
+	}
+	stale := append([]*streamConn(nil), u.idle[:n]...)
+	u.idle = append(u.idle[:0], u.idle[n:]...)
+	var conn *streamConn
+	if len(u.idle) > 0 {
+		conn = u.idle[len(u.idle)-1]
+		u.idle = u.idle[:len(u.idle)-1]
+	}
+	u.mu.Unlock()
+
+	for _, c := range stale {
+		_ = u.releaseConn(c, false)
+	}
+	return conn
+}
+
+// closeIdle closes all idle connections
+func (u *upstream) closeIdle() {
+	u.mu.Lock()
+	idle := u.idle
+	u.idle = nil
+	u.mu.Unlock()
+
+	for _, c := range idle {
+		_ = u.releaseConn(c, false)
+	}
+}
+
+// discardTrailer reads the trailer of a chunked body up to the final CRLF
+func discardTrailer(br *bufio.Reader) error {
+	for {
+		line, err := br.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		if len(line) <= 2 && (string(line) == "\r\n" || string(line) == "\n") {
+			return nil
+		}
+	}
+}
+
+func (u *upstream) dial(timeout time.Duration) (net.Conn, error) {
+	addr := addMissingPort(u.addr, u.client.IsTLS)
+	if !u.client.IsTLS {
+		if timeout > 0 {
+			return fasthttp.DialTimeout(addr, timeout)
+		}
+		return fasthttp.Dial(addr)
+	}
+
+	cfg := &tls.Config{}
+	if u.client.TLSConfig != nil {
+		cfg = u.client.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, cfg)
+}
@@ -1,9 +1,16 @@
 package recover
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 // Config defines the config for middleware.
@@ -12,11 +19,32 @@ type Config struct {
 	//
 	// Optional. Default: nil
 	Next func(c *fiber.Ctx) bool
+
+	// EnableStackTrace calls the StackTraceHandler when a panic is
+	// recovered, except for intentional aborts with http.ErrAbortHandler.
+	//
+	// Optional. Default: false
+	EnableStackTrace bool
+
+	// StackTraceHandler reports the recovered value e, it is called from
+	// the deferred function so runtime/debug.Stack() returns the stack of
+	// the panic. Use requestid.FromContext to get the request ID for the report.
+	//
+	// Optional. Default: writes the value, route, request ID and stack to Output
+	StackTraceHandler func(c *fiber.Ctx, e interface{})
+
+	// Output is the writer of the default StackTraceHandler.
+	//
+	// Optional. Default: os.Stderr
+	Output io.Writer
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next: nil,
+	Next:              nil,
+	EnableStackTrace:  false,
+	StackTraceHandler: nil,
+	Output:            os.Stderr,
 }
 
 // New creates a new middleware handler
@@ -32,6 +60,12 @@ func New(config ...Config) fiber.Handler {
 		if cfg.Next == nil {
 			cfg.Next = ConfigDefault.Next
 		}
+		if cfg.Output == nil {
+			cfg.Output = ConfigDefault.Output
+		}
+	}
+	if cfg.StackTraceHandler == nil {
+		cfg.StackTraceHandler = stackTraceHandler(cfg.Output)
 	}
 
 	// Return new handler
@@ -49,6 +83,18 @@ func New(config ...Config) fiber.Handler {
 					// Set error that will call the global error handler
 					err = fmt.Errorf("%v", r)
 				}
+
+				// Intentional aborts close the connection without a response,
+				// they are neither reported nor passed to the ErrorHandler
+				if errors.Is(err, http.ErrAbortHandler) {
+					c.Context().HijackSetNoResponse(true)
+					c.Context().Hijack(func(net.Conn) {})
+					err = nil
+					return
+				}
+				if cfg.EnableStackTrace {
+					cfg.StackTraceHandler(c, r)
+				}
 			}
 		}()
 
@@ -56,3 +102,11 @@ func New(config ...Config) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// stackTraceHandler returns the default StackTraceHandler, which writes to out
+func stackTraceHandler(out io.Writer) func(c *fiber.Ctx, e interface{}) {
+	return func(c *fiber.Ctx, e interface{}) {
+		_, _ = fmt.Fprintf(out, "panic: %v\nroute: %s %s\nrequest id: %s\n\n%s\n",
+			e, c.Method(), c.Route().Path, requestid.FromContext(c), debug.Stack())
+	}
+}
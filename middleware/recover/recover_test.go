@@ -1,10 +1,16 @@
 package recover
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"runtime/debug"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/utils"
 )
 
@@ -27,3 +33,110 @@ func Test_Recover(t *testing.T) {
 	utils.AssertEqual(t, nil, err)
 	utils.AssertEqual(t, fiber.StatusTeapot, resp.StatusCode)
 }
+
+// go test -run Test_Recover_Error
+func Test_Recover_Error(t *testing.T) {
+	errPanic := fmt.Errorf("panic error")
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			utils.AssertEqual(t, errPanic, err)
+			return c.SendStatus(fiber.StatusTeapot)
+		},
+	})
+
+	app.Use(New())
+
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		panic(errPanic)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusTeapot, resp.StatusCode)
+}
+
+// go test -run Test_Recover_StackTrace
+func Test_Recover_StackTrace(t *testing.T) {
+	app := fiber.New()
+
+	var (
+		value     interface{}
+		route     string
+		requestID string
+		stack     string
+	)
+	app.Use(New(Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			value = e
+			route = c.Route().Path
+			requestID = requestid.FromContext(c)
+			stack = string(debug.Stack())
+		},
+	}))
+
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXRequestID, "abc")
+		panic("Hi, I'm an error!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/1", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+	utils.AssertEqual(t, "Hi, I'm an error!", value)
+	utils.AssertEqual(t, "/users/:id", route)
+	utils.AssertEqual(t, "abc", requestID)
+	utils.AssertEqual(t, true, strings.Contains(stack, "Test_Recover_StackTrace"))
+}
+
+// go test -run Test_Recover_Output
+func Test_Recover_Output(t *testing.T) {
+	app := fiber.New()
+
+	out := &bytes.Buffer{}
+	app.Use(New(Config{
+		EnableStackTrace: true,
+		Output:           out,
+	}))
+
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXRequestID, "abc")
+		panic("Hi, I'm an error!")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/1", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusInternalServerError, resp.StatusCode)
+	utils.AssertEqual(t, true, strings.HasPrefix(out.String(), "panic: Hi, I'm an error!\nroute: GET /users/:id\nrequest id: abc\n"))
+	utils.AssertEqual(t, true, strings.Contains(out.String(), "Test_Recover_Output"))
+}
+
+// go test -run Test_Recover_Abort
+func Test_Recover_Abort(t *testing.T) {
+	handled := false
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			handled = true
+			return fiber.DefaultErrorHandler(c, err)
+		},
+	})
+
+	called := false
+	app.Use(New(Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			called = true
+		},
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	// The connection is closed without a response
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	utils.AssertEqual(t, true, err != nil)
+	utils.AssertEqual(t, true, resp == nil)
+	utils.AssertEqual(t, false, handled)
+	utils.AssertEqual(t, false, called)
+}
@@ -34,6 +34,9 @@ var ConfigDefault = Config{
 	},
 }
 
+// localsKey is the Locals key of the request ID
+const localsKey = "requestid"
+
 // New creates a new middleware handler
 func New(config ...Config) fiber.Handler {
 	// Set default config
@@ -66,8 +69,21 @@ func New(config ...Config) fiber.Handler {
 
 		// Set new id to response header
 		c.Set(cfg.Header, rid)
+		c.Locals(localsKey, rid)
 
 		// Continue stack
 		return c.Next()
 	}
 }
+
+// FromContext returns the request ID set by the middleware, without the
+// middleware it returns the X-Request-ID header of the response or request.
+func FromContext(c *fiber.Ctx) string {
+	if id, ok := c.Locals(localsKey).(string); ok {
+		return id
+	}
+	if id := c.Response().Header.Peek(fiber.HeaderXRequestID); len(id) > 0 {
+		return string(id)
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
@@ -1,6 +1,7 @@
 package requestid
 
 import (
+	"io/ioutil"
 	"net/http/httptest"
 	"testing"
 
@@ -33,3 +34,37 @@ func Test_RequestID(t *testing.T) {
 	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
 	utils.AssertEqual(t, reqid, resp.Header.Get(fiber.HeaderXRequestID))
 }
+
+// go test -run Test_RequestID_FromContext
+func Test_RequestID_FromContext(t *testing.T) {
+	app := fiber.New()
+
+	app.Use("/custom", New(Config{
+		Header: "X-Custom-ID",
+		Generator: func() string {
+			return "custom"
+		},
+	}))
+
+	app.Get("/custom", func(c *fiber.Ctx) error {
+		return c.SendString(FromContext(c))
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(FromContext(c))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/custom", nil))
+	utils.AssertEqual(t, nil, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "custom", string(body))
+
+	// Without the middleware the header of the request is used
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "request")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "request", string(body))
+}
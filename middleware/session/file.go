@@ -0,0 +1,110 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStorage is a fiber.Storage that keeps every key in its own file, it is meant
+// for local development where sessions should survive a restart.
+// Expired keys are removed when they are read.
+type FileStorage struct {
+	mutex sync.RWMutex
+	dir   string
+}
+
+// NewFileStorage creates a new file storage in dir, the directory is created
+// if it doesn't exist
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+// Get implements fiber.Storage
+func (s *FileStorage) Get(key string) ([]byte, error) {
+	if len(key) <= 0 {
+		return nil, nil
+	}
+	s.mutex.RLock()
+	raw, err := ioutil.ReadFile(s.path(key))
+	s.mutex.RUnlock()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The first 8 bytes hold the expiration as unix nanoseconds
+	if len(raw) < 8 {
+		return nil, nil
+	}
+	if expiry := int64(binary.BigEndian.Uint64(raw[:8])); expiry != 0 && expiry <= time.Now().UnixNano() {
+		return nil, s.Delete(key)
+	}
+	return raw[8:], nil
+}
+
+// Set implements fiber.Storage
+func (s *FileStorage) Set(key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	var expiry int64
+	if exp > 0 {
+		expiry = time.Now().Add(exp).UnixNano()
+	}
+	raw := make([]byte, 8+len(val))
+	binary.BigEndian.PutUint64(raw[:8], uint64(expiry))
+	copy(raw[8:], val)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return ioutil.WriteFile(s.path(key), raw, 0600)
+}
+
+// Delete implements fiber.Storage
+func (s *FileStorage) Delete(key string) error {
+	if len(key) <= 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reset implements fiber.Storage
+func (s *FileStorage) Reset() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = os.Remove(filepath.Join(s.dir, file.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements fiber.Storage
+func (s *FileStorage) Close() error {
+	return nil
+}
+
+// path encodes the key so it is safe to use as a file name
+func (s *FileStorage) path(key string) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
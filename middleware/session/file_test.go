@@ -0,0 +1,57 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// go test -run Test_FileStorage
+func Test_FileStorage(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "fiber-session")
+	utils.AssertEqual(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStorage(dir)
+	utils.AssertEqual(t, nil, err)
+
+	val, err := s.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	// Empty keys and values are ignored
+	utils.AssertEqual(t, nil, s.Set("", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, s.Set("john", nil, 0))
+	val, err = s.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, s.Set("john", []byte("doe"), 0))
+	val, err = s.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "doe", string(val))
+
+	utils.AssertEqual(t, nil, s.Set("jane", []byte("doe"), 50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	val, err = s.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, s.Delete("john"))
+	utils.AssertEqual(t, nil, s.Delete("unknown"))
+	val, err = s.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, s.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, s.Reset())
+	val, err = s.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, s.Close())
+}
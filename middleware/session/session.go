@@ -0,0 +1,153 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Session holds the data of a single client, changes are only stored when
+// Save is called.
+type Session struct {
+	mutex sync.RWMutex
+	id    string
+	fresh bool
+	ctx   *fiber.Ctx
+	store *Store
+	data  map[string]interface{}
+	exp   time.Duration
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.id
+}
+
+// Fresh is true if the session was created by this request
+func (s *Session) Fresh() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.fresh
+}
+
+// Get returns the value of key, nil if it doesn't exist
+func (s *Session) Get(key string) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.data[key]
+}
+
+// Set stores val under key
+func (s *Session) Set(key string, val interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = val
+}
+
+// Delete removes key from the session
+func (s *Session) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+}
+
+// Keys returns all keys of the session
+func (s *Session) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// SetExpiry overrides the store expiration for this session
+func (s *Session) SetExpiry(exp time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.exp = exp
+}
+
+// Destroy deletes the session from the storage and expires the client ID
+func (s *Session) Destroy() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data = make(map[string]interface{})
+	if err := s.store.Storage.Delete(keyPrefix + s.id); err != nil {
+		return err
+	}
+	s.delSessionID()
+	return nil
+}
+
+// Regenerate deletes the stored session and assigns a new ID while keeping
+// the data, use it after a login to prevent session fixation.
+// Call Save to store the session under its new ID.
+func (s *Session) Regenerate() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.store.Storage.Delete(keyPrefix + s.id); err != nil {
+		return err
+	}
+	s.id = s.store.KeyGenerator()
+	s.fresh = true
+	return nil
+}
+
+// Save stores the session data and sends the session ID to the client
+func (s *Session) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.data); err != nil {
+		return err
+	}
+	if err := s.store.Storage.Set(keyPrefix+s.id, buf.Bytes(), s.exp); err != nil {
+		return err
+	}
+	s.setSessionID()
+	return nil
+}
+
+func (s *Session) setSessionID() {
+	if s.store.source == sourceHeader {
+		s.ctx.Set(s.store.name, s.id)
+		return
+	}
+	s.ctx.Cookie(&fiber.Cookie{
+		Name:     s.store.name,
+		Value:    s.id,
+		Path:     s.store.CookiePath,
+		Domain:   s.store.CookieDomain,
+		Expires:  time.Now().Add(s.exp),
+		Secure:   s.store.CookieSecure,
+		HTTPOnly: s.store.CookieHTTPOnly,
+		SameSite: s.store.CookieSameSite,
+	})
+}
+
+func (s *Session) delSessionID() {
+	if s.store.source == sourceHeader {
+		s.ctx.Request().Header.Del(s.store.name)
+		s.ctx.Response().Header.Del(s.store.name)
+		return
+	}
+	s.ctx.Request().Header.DelCookie(s.store.name)
+	s.ctx.Cookie(&fiber.Cookie{
+		Name:     s.store.name,
+		Value:    "",
+		Path:     s.store.CookiePath,
+		Domain:   s.store.CookieDomain,
+		Expires:  time.Now().Add(-time.Hour),
+		Secure:   s.store.CookieSecure,
+		HTTPOnly: s.store.CookieHTTPOnly,
+		SameSite: s.store.CookieSameSite,
+	})
+}
@@ -0,0 +1,173 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_Session
+func Test_Session(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	store := New()
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	// New session
+	sess, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, 43, len(sess.ID()))
+	utils.AssertEqual(t, 0, len(sess.Keys()))
+
+	sess.Set("name", "john")
+	sess.Set("visits", 1)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Get("unknown"))
+	utils.AssertEqual(t, 2, len(sess.Keys()))
+	sess.Delete("visits")
+	utils.AssertEqual(t, nil, sess.Get("visits"))
+	utils.AssertEqual(t, nil, sess.Save())
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("session_id")
+	utils.AssertEqual(t, true, c.Response().Header.Cookie(cookie))
+	utils.AssertEqual(t, sess.ID(), string(cookie.Value()))
+
+	// Existing session
+	c.Request().Header.SetCookie("session_id", sess.ID())
+	sess2, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, sess2.Fresh())
+	utils.AssertEqual(t, sess.ID(), sess2.ID())
+	utils.AssertEqual(t, "john", sess2.Get("name"))
+
+	// Unknown IDs are replaced
+	c.Request().Header.SetCookie("session_id", "chosen-by-client")
+	sess3, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, sess3.Fresh())
+	utils.AssertEqual(t, true, sess3.ID() != "chosen-by-client")
+}
+
+// go test -run Test_Session_Shared_Storage
+func Test_Session_Shared_Storage(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	storage := memory.New()
+	store := New(Config{Storage: storage})
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	// A key of another middleware is not a session
+	utils.AssertEqual(t, nil, storage.Set("token", []byte("not a session"), 0))
+	c.Request().Header.SetCookie("session_id", "token")
+	sess, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, sess.Fresh())
+
+	utils.AssertEqual(t, nil, sess.Save())
+	raw, err := storage.Get("token")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("not a session"), raw)
+}
+
+// go test -run Test_Session_Regenerate
+func Test_Session_Regenerate(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	store := New()
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	sess, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	oldID := sess.ID()
+
+	utils.AssertEqual(t, nil, sess.Regenerate())
+	utils.AssertEqual(t, true, sess.ID() != oldID)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Save())
+
+	raw, err := store.Storage.Get(keyPrefix + oldID)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, raw == nil)
+
+	c.Request().Header.SetCookie("session_id", sess.ID())
+	sess, err = store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+}
+
+// go test -run Test_Session_Destroy
+func Test_Session_Destroy(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	store := New(Config{KeyLookup: "header:X-Session-ID"})
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	sess, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, sess.ID(), string(c.Response().Header.Peek("X-Session-ID")))
+
+	c.Request().Header.Set("X-Session-ID", sess.ID())
+	sess, err = store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	utils.AssertEqual(t, nil, sess.Destroy())
+	utils.AssertEqual(t, "", string(c.Response().Header.Peek("X-Session-ID")))
+	utils.AssertEqual(t, 0, len(sess.Keys()))
+
+	raw, err := store.Storage.Get(keyPrefix + sess.ID())
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, raw == nil)
+}
+
+// go test -run Test_Session_Expiration
+func Test_Session_Expiration(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	store := New(Config{Expiration: time.Second})
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	sess, err := store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	sess.Set("name", "john")
+	sess.SetExpiry(100 * time.Millisecond)
+	utils.AssertEqual(t, nil, sess.Save())
+
+	time.Sleep(150 * time.Millisecond)
+
+	c.Request().Header.SetCookie("session_id", sess.ID())
+	sess, err = store.Get(c)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+}
+
+// go test -run Test_Session_KeyLookup_Panic
+func Test_Session_KeyLookup_Panic(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		utils.AssertEqual(t, "session: KeyLookup must be in the form of <cookie|header>:<name>", recover())
+	}()
+	New(Config{KeyLookup: "query:id"})
+}
@@ -0,0 +1,187 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/storage/memory"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Config defines the config for the session store.
+type Config struct {
+	// Expiration is the time a session is kept after it was last saved.
+	//
+	// Optional. Default: 24 * time.Hour
+	Expiration time.Duration
+
+	// Storage is used to store the session data.
+	//
+	// Optional. Default: an in-memory storage
+	Storage fiber.Storage
+
+	// KeyLookup is a string in the form of "<source>:<name>" that is used
+	// to extract the session ID from the request.
+	//
+	// Optional. Default value "cookie:session_id".
+	// Possible values:
+	// - "cookie:<name>"
+	// - "header:<name>"
+	KeyLookup string
+
+	// Domain of the session cookie.
+	//
+	// Optional. Default: ""
+	CookieDomain string
+
+	// Path of the session cookie.
+	//
+	// Optional. Default: ""
+	CookiePath string
+
+	// Indicates if the session cookie should only be sent over HTTPS.
+	//
+	// Optional. Default: false
+	CookieSecure bool
+
+	// Indicates if the session cookie is hidden from client side scripts.
+	//
+	// Optional. Default: false
+	CookieHTTPOnly bool
+
+	// SameSite attribute of the session cookie: "Lax", "Strict" or "None".
+	//
+	// Optional. Default: "Lax"
+	CookieSameSite string
+
+	// KeyGenerator generates the session ID.
+	//
+	// Optional. Default: 32 random bytes, base64 url encoded
+	KeyGenerator func() string
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Expiration:     24 * time.Hour,
+	KeyLookup:      "cookie:session_id",
+	CookieSameSite: "Lax",
+	KeyGenerator:   generateID,
+}
+
+// keyPrefix separates the session IDs from the keys of other middlewares
+// sharing the storage
+const keyPrefix = "session:"
+
+// Store manages the sessions of an app
+type Store struct {
+	Config
+	source string
+	name   string
+}
+
+const (
+	sourceCookie = "cookie"
+	sourceHeader = "header"
+)
+
+// New creates a new session store
+func New(config ...Config) *Store {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Expiration <= 0 {
+			cfg.Expiration = ConfigDefault.Expiration
+		}
+		if cfg.KeyLookup == "" {
+			cfg.KeyLookup = ConfigDefault.KeyLookup
+		}
+		if cfg.CookieSameSite == "" {
+			cfg.CookieSameSite = ConfigDefault.CookieSameSite
+		}
+		if cfg.KeyGenerator == nil {
+			cfg.KeyGenerator = ConfigDefault.KeyGenerator
+		}
+	}
+	if cfg.Storage == nil {
+		cfg.Storage = memory.New()
+	}
+
+	selectors := strings.Split(cfg.KeyLookup, ":")
+	if len(selectors) != 2 || (selectors[0] != sourceCookie && selectors[0] != sourceHeader) {
+		panic("session: KeyLookup must be in the form of <cookie|header>:<name>")
+	}
+
+	return &Store{
+		Config: cfg,
+		source: selectors[0],
+		name:   selectors[1],
+	}
+}
+
+// RegisterType registers a custom type for encoding and decoding session values
+func (s *Store) RegisterType(i interface{}) {
+	gob.Register(i)
+}
+
+// Get returns the session of the request, a new session is created when the
+// request has no session ID or the ID is unknown or expired.
+func (s *Store) Get(c *fiber.Ctx) (*Session, error) {
+	sess := &Session{
+		ctx:   c,
+		store: s,
+		data:  make(map[string]interface{}),
+		exp:   s.Expiration,
+	}
+
+	id := s.getSessionID(c)
+	if id != "" {
+		raw, err := s.Storage.Get(keyPrefix + id)
+		if err != nil {
+			return nil, err
+		}
+		// Unknown IDs are never reused, a client can't choose its own session ID
+		if raw != nil {
+			if err = gob.NewDecoder(bytes.NewReader(raw)).Decode(&sess.data); err != nil {
+				return nil, err
+			}
+			sess.id = id
+			return sess, nil
+		}
+	}
+
+	sess.id = s.KeyGenerator()
+	sess.fresh = true
+	return sess, nil
+}
+
+// Reset deletes all keys from the storage, including those of other
+// middlewares sharing it
+func (s *Store) Reset() error {
+	return s.Storage.Reset()
+}
+
+func (s *Store) getSessionID(c *fiber.Ctx) string {
+	if s.source == sourceHeader {
+		return utils.ImmutableString(c.Get(s.name))
+	}
+	return utils.ImmutableString(c.Cookies(s.name))
+}
+
+// generateID returns 32 random bytes, base64 url encoded
+func generateID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return utils.UUID()
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -1,35 +1,40 @@
 package timeout
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
 )
 
-// New wraps a handler and aborts the process of the handler if the timeout is reached
+// New wraps a handler with a deadline of the given duration on c.UserContext().
+// The handler should pass c.UserContext() to blocking calls and return when it
+// is done, if the deadline is exceeded the response of the handler is discarded
+// and fiber.ErrRequestTimeout is returned.
 func New(handler fiber.Handler, timeout time.Duration) fiber.Handler {
 	if timeout <= 0 {
 		return handler
 	}
 
-	// logic is from fasthttp.TimeoutWithCodeHandler https://github.com/valyala/fasthttp/blob/master/server.go#L418
-	return func(ctx *fiber.Ctx) error {
-		ch := make(chan struct{}, 1)
+	return func(c *fiber.Ctx) error {
+		parent := c.UserContext()
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		defer c.SetUserContext(parent)
 
-		go func() {
-			defer func() {
-				_ = recover()
-			}()
-			_ = handler(ctx)
-			ch <- struct{}{}
-		}()
+		// Keep the response headers set before the handler
+		var header fasthttp.ResponseHeader
+		c.Response().Header.CopyTo(&header)
 
-		select {
-		case <-ch:
-		case <-time.After(timeout):
+		err := handler(c)
+		if ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded) {
+			header.CopyTo(&c.Response().Header)
+			c.Response().ResetBody()
 			return fiber.ErrRequestTimeout
 		}
-
-		return nil
+		return err
 	}
 }
@@ -0,0 +1,586 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The message types are defined in RFC 6455, section 11.8.
+const (
+	// TextMessage denotes a text data message. The text message payload is
+	// interpreted as UTF-8 encoded text data.
+	TextMessage = 1
+
+	// BinaryMessage denotes a binary data message.
+	BinaryMessage = 2
+
+	// CloseMessage denotes a close control message. The optional message
+	// payload contains a numeric code and text. Use the FormatCloseMessage
+	// function to format a close message payload.
+	CloseMessage = 8
+
+	// PingMessage denotes a ping control message. The optional message payload
+	// is UTF-8 encoded text.
+	PingMessage = 9
+
+	// PongMessage denotes a pong control message. The optional message payload
+	// is UTF-8 encoded text.
+	PongMessage = 10
+)
+
+// Close codes defined in RFC 6455, section 11.7.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+	CloseServiceRestart          = 1012
+	CloseTryAgainLater           = 1013
+	CloseTLSHandshake            = 1015
+)
+
+const (
+	continuationFrame = 0
+
+	finalBit = 1 << 7
+	rsvBits  = 7 << 4
+	maskBit  = 1 << 7
+
+	maxControlFramePayloadSize = 125
+
+	// Payloads larger than this are read in chunks
+	payloadChunkSize = 64 * 1024
+
+	// Time allowed to write the control messages sent by the connection itself
+	writeWait = time.Second
+)
+
+var (
+	// ErrCloseSent is returned when the application writes a message to the
+	// connection after sending a close message.
+	ErrCloseSent = errors.New("websocket: close sent")
+
+	// ErrReadLimit is returned when reading a message that is larger than the
+	// read limit set for the connection.
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+
+	// ErrBadHandshake is returned by Test when the server does not accept
+	// the WebSocket handshake.
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+
+	errBadWriteOpCode      = errors.New("websocket: bad write message type")
+	errInvalidControlFrame = errors.New("websocket: invalid control frame")
+	errInvalidUTF8         = errors.New("websocket: invalid UTF-8 in text message")
+)
+
+// protocolError is a violation of RFC 6455 by the peer
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "websocket: " + string(e)
+}
+
+// CloseError is returned by ReadMessage when the peer sends a close message
+// or the connection is closed without one.
+type CloseError struct {
+	// Code is defined in RFC 6455, section 11.7.
+	Code int
+
+	// Text is the optional text payload.
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	s := "websocket: close " + strconv.Itoa(e.Code)
+	if e.Text != "" {
+		s += ": " + e.Text
+	}
+	return s
+}
+
+// IsCloseError returns true if err is a *CloseError with one of the given codes.
+func IsCloseError(err error, codes ...int) bool {
+	if e, ok := err.(*CloseError); ok {
+		for _, code := range codes {
+			if e.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUnexpectedCloseError returns true if err is a *CloseError with a code
+// that is not in the list of expected codes.
+func IsUnexpectedCloseError(err error, expectedCodes ...int) bool {
+	if e, ok := err.(*CloseError); ok {
+		for _, code := range expectedCodes {
+			if e.Code == code {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// FormatCloseMessage formats closeCode and text as a WebSocket close message.
+// An empty message is returned for code CloseNoStatusReceived.
+func FormatCloseMessage(closeCode int, text string) []byte {
+	if closeCode == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(closeCode))
+	copy(buf[2:], text)
+	return buf
+}
+
+// Conn represents a WebSocket connection.
+//
+// Applications are responsible for ensuring that no more than one goroutine
+// calls the read methods concurrently, the write methods can be called from
+// multiple goroutines.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+
+	readLimit int64
+	readErr   error
+
+	writeMu       sync.Mutex
+	writeDeadline time.Time
+	closeSent     bool
+
+	handlePing  func(appData string) error
+	handlePong  func(appData string) error
+	handleClose func(code int, text string) error
+
+	// Copied from the upgrade request
+	locals  map[string]interface{}
+	params  map[string]string
+	queries map[string]string
+	cookies map[string]string
+}
+
+func (c *Conn) init(conn net.Conn, br *bufio.Reader, isServer bool) {
+	c.conn = conn
+	c.br = br
+	c.isServer = isServer
+	c.SetPingHandler(nil)
+	c.SetPongHandler(nil)
+	c.SetCloseHandler(nil)
+}
+
+// Locals returns a value stored in the Locals of the upgrade request.
+func (c *Conn) Locals(key string) interface{} {
+	return c.locals[key]
+}
+
+// Params returns a route parameter of the upgrade request.
+// Defaults to empty string "" if the param doesn't exist.
+// If a default value is given, it will return that value if the param doesn't exist.
+func (c *Conn) Params(key string, defaultValue ...string) string {
+	return lookup(c.params, key, defaultValue)
+}
+
+// Query returns a query string parameter of the upgrade request.
+// Defaults to empty string "" if the query doesn't exist.
+// If a default value is given, it will return that value if the query doesn't exist.
+func (c *Conn) Query(key string, defaultValue ...string) string {
+	return lookup(c.queries, key, defaultValue)
+}
+
+// Cookies returns a cookie of the upgrade request.
+// Defaults to empty string "" if the cookie doesn't exist.
+// If a default value is given, it will return that value if the cookie doesn't exist.
+func (c *Conn) Cookies(key string, defaultValue ...string) string {
+	return lookup(c.cookies, key, defaultValue)
+}
+
+func lookup(m map[string]string, key string, defaultValue []string) string {
+	if v, ok := m[key]; ok && v != "" {
+		return v
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return ""
+}
+
+// Subprotocol returns the negotiated subprotocol of the connection.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// UnderlyingConn returns the underlying network connection.
+func (c *Conn) UnderlyingConn() net.Conn {
+	return c.conn
+}
+
+// Close closes the underlying network connection without sending a close message.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// SetReadDeadline sets the read deadline on the underlying network connection.
+// After a read has timed out, the WebSocket connection state is corrupt and
+// all future reads will return an error.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the following writes, a zero value
+// for t means writes will not time out.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeMu.Lock()
+	c.writeDeadline = t
+	c.writeMu.Unlock()
+	return nil
+}
+
+// SetReadLimit sets the maximum size in bytes of a message read from the peer.
+// If a message exceeds the limit, the connection sends a close message to the
+// peer and ReadMessage returns ErrReadLimit. Zero means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPingHandler sets the handler for ping messages received from the peer.
+// The default handler sends a pong message with the same application data.
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(appData string) error {
+			err := c.WriteControl(PongMessage, []byte(appData), time.Now().Add(writeWait))
+			if err == ErrCloseSent {
+				return nil
+			}
+			return err
+		}
+	}
+	c.handlePing = h
+}
+
+// SetPongHandler sets the handler for pong messages received from the peer.
+// The default handler does nothing.
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error {
+			return nil
+		}
+	}
+	c.handlePong = h
+}
+
+// SetCloseHandler sets the handler for close messages received from the peer.
+// The default handler sends a close message back to the peer. ReadMessage
+// returns a *CloseError after the handler is called.
+func (c *Conn) SetCloseHandler(h func(code int, text string) error) {
+	if h == nil {
+		h = func(code int, text string) error {
+			_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, ""), time.Now().Add(writeWait))
+			return nil
+		}
+	}
+	c.handleClose = h
+}
+
+// ReadMessage reads the next data message from the peer, control messages
+// are passed to the ping, pong and close handlers. The messageType is either
+// TextMessage or BinaryMessage.
+//
+// Once ReadMessage returns an error, all following calls return the same error.
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	for {
+		final, opcode, payload, err := c.readFrame(int64(len(p)))
+		if err != nil {
+			return c.readFailed(err)
+		}
+
+		switch opcode {
+		case PingMessage:
+			err = c.handlePing(string(payload))
+		case PongMessage:
+			err = c.handlePong(string(payload))
+		case CloseMessage:
+			err = c.readClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				err = protocolError("data frame inside a fragmented message")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				err = protocolError("continuation frame without a message")
+			}
+		default:
+			err = protocolError("unknown opcode " + strconv.Itoa(opcode))
+		}
+		if err != nil {
+			return c.readFailed(err)
+		}
+		if opcode >= CloseMessage {
+			continue
+		}
+
+		if p == nil {
+			p = payload
+		} else {
+			p = append(p, payload...)
+		}
+		if final {
+			if messageType == TextMessage && !utf8.Valid(p) {
+				return c.readFailed(errInvalidUTF8)
+			}
+			return messageType, p, nil
+		}
+	}
+}
+
+// readFrame reads a single frame, read is the size of the message so far
+func (c *Conn) readFrame(read int64) (final bool, opcode int, payload []byte, err error) {
+	var head [8]byte
+	if _, err = io.ReadFull(c.br, head[:2]); err != nil {
+		return
+	}
+
+	final = head[0]&finalBit != 0
+	opcode = int(head[0] & 0xf)
+	if head[0]&rsvBits != 0 {
+		err = protocolError("unexpected reserved bits")
+		return
+	}
+	// Clients must mask their frames, servers must not
+	masked := head[1]&maskBit != 0
+	if masked != c.isServer {
+		err = protocolError("incorrect mask flag")
+		return
+	}
+
+	length := int64(head[1] &^ maskBit)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.br, head[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(head[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, head[:8]); err != nil {
+			return
+		}
+		if length = int64(binary.BigEndian.Uint64(head[:8])); length < 0 {
+			err = protocolError("invalid payload length")
+			return
+		}
+	}
+
+	if opcode >= CloseMessage {
+		if !final || length > maxControlFramePayloadSize {
+			err = protocolError("invalid control frame")
+			return
+		}
+	} else if c.readLimit > 0 && length > c.readLimit-read {
+		err = ErrReadLimit
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	if payload, err = readPayload(c.br, length); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return
+}
+
+// readPayload reads length bytes from r, the buffer grows with the bytes
+// received instead of trusting the length sent by the peer
+func readPayload(r io.Reader, length int64) ([]byte, error) {
+	if length <= payloadChunkSize {
+		payload := make([]byte, length)
+		_, err := io.ReadFull(r, payload)
+		return payload, err
+	}
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, length)
+	if err == io.EOF && n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+// readClose parses a close message and calls the close handler
+func (c *Conn) readClose(payload []byte) error {
+	code := CloseNoStatusReceived
+	text := ""
+	if len(payload) == 1 {
+		return protocolError("invalid close message")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return protocolError("invalid close code " + strconv.Itoa(code))
+		}
+		if !utf8.ValidString(text) {
+			return protocolError("invalid UTF-8 in close message")
+		}
+	}
+	if err := c.handleClose(code, text); err != nil {
+		return err
+	}
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFailed closes the connection with the close code matching err and
+// makes it the error of all following reads
+func (c *Conn) readFailed(err error) (int, []byte, error) {
+	code := 0
+	switch err.(type) {
+	case protocolError:
+		code = CloseProtocolError
+	}
+	switch err {
+	case ErrReadLimit:
+		code = CloseMessageTooBig
+	case errInvalidUTF8:
+		code = CloseInvalidFramePayloadData
+	case io.EOF, io.ErrUnexpectedEOF:
+		err = &CloseError{Code: CloseAbnormalClosure, Text: io.ErrUnexpectedEOF.Error()}
+	}
+	if code != 0 {
+		_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, ""), time.Now().Add(writeWait))
+	}
+	c.readErr = err
+	return 0, nil, err
+}
+
+// WriteMessage writes a message of the given type to the peer.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+		return c.writeFrame(messageType, data, time.Time{})
+	case CloseMessage, PingMessage, PongMessage:
+		return c.WriteControl(messageType, data, time.Time{})
+	}
+	return errBadWriteOpCode
+}
+
+// WriteControl writes a close, ping or pong message with the given deadline,
+// a zero deadline uses the deadline set by SetWriteDeadline.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return errBadWriteOpCode
+	}
+	if len(data) > maxControlFramePayloadSize {
+		return errInvalidControlFrame
+	}
+	return c.writeFrame(messageType, data, deadline)
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	var mask byte
+	if !c.isServer {
+		mask = maskBit
+	}
+	frame := make([]byte, 0, 14+len(data))
+	frame = append(frame, finalBit|byte(opcode))
+	switch n := len(data); {
+	case n <= 125:
+		frame = append(frame, mask|byte(n))
+	case n <= 65535:
+		frame = append(frame, mask|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, mask|127)
+		frame = frame[:10]
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+
+	if c.isServer {
+		frame = append(frame, data...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(key, frame[start:])
+	}
+
+	if deadline.IsZero() {
+		deadline = c.writeDeadline
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// validCloseCode reports whether a close code may be sent by a peer
+// https://datatracker.ietf.org/doc/html/rfc6455#section-7.4.1
+func validCloseCode(code int) bool {
+	switch code {
+	case CloseNormalClosure, CloseGoingAway, CloseProtocolError, CloseUnsupportedData,
+		CloseInvalidFramePayloadData, ClosePolicyViolation, CloseMessageTooBig,
+		CloseMandatoryExtension, CloseInternalServerErr, CloseServiceRestart, CloseTryAgainLater:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
@@ -0,0 +1,310 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// Origins is the list of origins that are allowed to connect besides
+	// the host of the request, the Origin header is not checked when it
+	// contains "*". Requests without an Origin header are always allowed.
+	//
+	// Optional. Default: nil
+	Origins []string
+
+	// Subprotocols is the list of supported subprotocols in order of
+	// preference, the first one requested by the client is selected.
+	//
+	// Optional. Default: nil
+	Subprotocols []string
+
+	// ReadBufferSize is the size in bytes of the read buffer of a connection.
+	//
+	// Optional. Default: 4096
+	ReadBufferSize int
+
+	// ReadLimit is the maximum size in bytes of a message read from the
+	// peer, larger messages close the connection with CloseMessageTooBig.
+	// A negative value disables the limit.
+	//
+	// Optional. Default: 4 * 1024 * 1024
+	ReadLimit int64
+
+	// PanicHandler reports a panic of the handler, the connection is closed
+	// with CloseInternalServerErr after it returns. It is called from the
+	// deferred function so runtime/debug.Stack() returns the stack of the panic.
+	//
+	// Optional. Default: writes the value and stack to Output
+	PanicHandler func(conn *Conn, e interface{})
+
+	// Output is the writer of the default PanicHandler.
+	//
+	// Optional. Default: os.Stderr
+	Output io.Writer
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:           nil,
+	Origins:        nil,
+	ReadBufferSize: 4096,
+	ReadLimit:      4 * 1024 * 1024,
+	PanicHandler:   nil,
+	Output:         os.Stderr,
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6455#section-1.3
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// New creates a new middleware handler that upgrades the request to a
+// WebSocket connection and calls handler with it
+func New(handler func(*Conn), config ...Config) fiber.Handler {
+	// Set default config
+	cfg := ConfigDefault
+
+	// Override config if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		// Set default values
+		if cfg.Next == nil {
+			cfg.Next = ConfigDefault.Next
+		}
+		if cfg.ReadBufferSize <= 0 {
+			cfg.ReadBufferSize = ConfigDefault.ReadBufferSize
+		}
+		if cfg.ReadLimit == 0 {
+			cfg.ReadLimit = ConfigDefault.ReadLimit
+		}
+		if cfg.Output == nil {
+			cfg.Output = ConfigDefault.Output
+		}
+	}
+	if cfg.PanicHandler == nil {
+		cfg.PanicHandler = panicHandler(cfg.Output)
+	}
+
+	// Return new handler
+	return func(c *fiber.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		if !IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		if c.Get(fiber.HeaderSecWebSocketVersion) != "13" {
+			c.Set(fiber.HeaderSecWebSocketVersion, "13")
+			return fiber.ErrUpgradeRequired
+		}
+		key := c.Get(fiber.HeaderSecWebSocketKey)
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+			return fiber.ErrBadRequest
+		}
+		if !cfg.allowOrigin(c.Get(fiber.HeaderOrigin), c.Hostname()) {
+			return fiber.ErrForbidden
+		}
+
+		// Copy the request values, the context is released before the handler runs
+		conn := &Conn{
+			subprotocol: cfg.selectSubprotocol(c.Get(fiber.HeaderSecWebSocketProtocol)),
+			locals:      make(map[string]interface{}),
+			params:      make(map[string]string),
+			queries:     make(map[string]string),
+			cookies:     make(map[string]string),
+		}
+		c.Context().VisitUserValues(func(key []byte, value interface{}) {
+			conn.locals[string(key)] = value
+		})
+		for _, param := range c.Route().Params {
+			conn.params[param] = utils.ImmutableString(c.Params(param))
+		}
+		c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+			if _, ok := conn.queries[string(key)]; !ok {
+				conn.queries[string(key)] = string(value)
+			}
+		})
+		c.Request().Header.VisitAllCookie(func(key, value []byte) {
+			conn.cookies[string(key)] = string(value)
+		})
+
+		c.Status(fiber.StatusSwitchingProtocols)
+		c.Set(fiber.HeaderUpgrade, "websocket")
+		c.Set(fiber.HeaderConnection, "Upgrade")
+		c.Set(fiber.HeaderSecWebSocketAccept, acceptKey(key))
+		if conn.subprotocol != "" {
+			c.Set(fiber.HeaderSecWebSocketProtocol, conn.subprotocol)
+		}
+
+		c.Context().Hijack(func(netConn net.Conn) {
+			// Clear the deadlines set by the server
+			_ = netConn.SetDeadline(time.Time{})
+			conn.init(netConn, bufio.NewReaderSize(netConn, cfg.ReadBufferSize), true)
+			if cfg.ReadLimit > 0 {
+				conn.SetReadLimit(cfg.ReadLimit)
+			}
+			// Don't let a panic of one connection crash the server
+			defer func() {
+				if r := recover(); r != nil {
+					cfg.PanicHandler(conn, r)
+					_ = conn.WriteControl(CloseMessage, FormatCloseMessage(CloseInternalServerErr, ""), time.Now().Add(writeWait))
+				}
+			}()
+			handler(conn)
+		})
+		return nil
+	}
+}
+
+// panicHandler returns the default PanicHandler writing to out
+func panicHandler(out io.Writer) func(*Conn, interface{}) {
+	return func(_ *Conn, e interface{}) {
+		_, _ = fmt.Fprintf(out, "websocket: panic: %v\n%s", e, debug.Stack())
+	}
+}
+
+// IsWebSocketUpgrade returns true if the client requested a WebSocket upgrade.
+func IsWebSocketUpgrade(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodGet &&
+		strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") &&
+		hasToken(c.Get(fiber.HeaderConnection), "upgrade")
+}
+
+// allowOrigin returns true if origin is the host of the request or one of
+// the allowed origins, which prevents cross-site WebSocket hijacking
+func (cfg Config) allowOrigin(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" &&
+		strings.EqualFold(trimDefaultPort(u.Host, u.Scheme), trimDefaultPort(host, u.Scheme)) {
+		return true
+	}
+	for _, o := range cfg.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimDefaultPort removes the default port of scheme from host, so
+// "https://example.com" matches the host "example.com:443"
+func trimDefaultPort(host, scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http", "ws":
+		return strings.TrimSuffix(host, ":80")
+	case "https", "wss":
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+func (cfg Config) selectSubprotocol(header string) string {
+	for _, protocol := range cfg.Subprotocols {
+		if hasToken(header, protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// hasToken returns true if the comma-separated header contains token
+func hasToken(header, token string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.EqualFold(utils.Trim(t, ' '), token) {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Test performs the WebSocket handshake of req with app over an in-memory
+// connection and returns the client side of the connection, like App.Test
+// does for HTTP requests. The upgrade headers are added to req if missing.
+func Test(app *fiber.App, req *http.Request) (*Conn, *http.Response, error) {
+	if req.Header.Get(fiber.HeaderUpgrade) == "" {
+		req.Header.Set(fiber.HeaderConnection, "Upgrade")
+		req.Header.Set(fiber.HeaderUpgrade, "websocket")
+	}
+	if req.Header.Get(fiber.HeaderSecWebSocketVersion) == "" {
+		req.Header.Set(fiber.HeaderSecWebSocketVersion, "13")
+	}
+	key := req.Header.Get(fiber.HeaderSecWebSocketKey)
+	if key == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		key = base64.StdEncoding.EncodeToString(b)
+		req.Header.Set(fiber.HeaderSecWebSocketKey, key)
+	}
+
+	// Dump raw http request
+	dump, err := httputil.DumpRequest(req, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pipe := fasthttputil.NewPipeConns()
+	client := pipe.Conn1()
+	go func() {
+		_ = fasthttp.ServeConn(pipe.Conn2(), app.Handler())
+	}()
+
+	if _, err = client.Write(dump); err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(client)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != fiber.StatusSwitchingProtocols ||
+		resp.Header.Get(fiber.HeaderSecWebSocketAccept) != acceptKey(key) {
+		// Keep the body readable after the connection is closed
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		_ = client.Close()
+		return nil, resp, ErrBadHandshake
+	}
+
+	conn := &Conn{subprotocol: resp.Header.Get(fiber.HeaderSecWebSocketProtocol)}
+	conn.init(client, br, false)
+	return conn, resp, nil
+}
@@ -0,0 +1,374 @@
+package websocket
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func echo(c *Conn) {
+	for {
+		mt, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err = c.WriteMessage(mt, msg); err != nil {
+			return
+		}
+	}
+}
+
+// go test -run Test_WebSocket
+func Test_WebSocket(t *testing.T) {
+	app := fiber.New()
+
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", "john")
+		return c.Next()
+	})
+	app.Get("/ws/:id", New(func(c *Conn) {
+		msg := c.Locals("user").(string) + "," + c.Params("id") + "," +
+			c.Query("q") + "," + c.Cookies("session") + "," + c.Params("none", "default")
+		if err := c.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			return
+		}
+		echo(c)
+	}))
+
+	req := httptest.NewRequest("GET", "/ws/42?q=search", nil)
+	req.Header.Set(fiber.HeaderCookie, "session=abc")
+	conn, resp, err := Test(app, req)
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+	utils.AssertEqual(t, fiber.StatusSwitchingProtocols, resp.StatusCode)
+	utils.AssertEqual(t, "websocket", resp.Header.Get(fiber.HeaderUpgrade))
+
+	mt, msg, err := conn.ReadMessage()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, TextMessage, mt)
+	utils.AssertEqual(t, "john,42,search,abc,default", string(msg))
+
+	// Text and binary messages of every payload length encoding
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte('a' + i%26)
+		}
+		for _, typ := range []int{TextMessage, BinaryMessage} {
+			utils.AssertEqual(t, nil, conn.WriteMessage(typ, data))
+			mt, msg, err = conn.ReadMessage()
+			utils.AssertEqual(t, nil, err)
+			utils.AssertEqual(t, typ, mt)
+			utils.AssertEqual(t, data, msg)
+		}
+	}
+}
+
+// go test -run Test_WebSocket_Handshake
+func Test_WebSocket_Handshake(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/ws", New(echo, Config{
+		Origins:      []string{"https://gofiber.io"},
+		Subprotocols: []string{"json", "text"},
+	}))
+
+	// Not an upgrade request
+	resp, err := app.Test(httptest.NewRequest("GET", "/ws", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+
+	// Unsupported version
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderSecWebSocketVersion, "8")
+	_, resp, err = Test(app, req)
+	utils.AssertEqual(t, ErrBadHandshake, err)
+	utils.AssertEqual(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+	utils.AssertEqual(t, "13", resp.Header.Get(fiber.HeaderSecWebSocketVersion))
+
+	// Invalid key
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderSecWebSocketKey, "invalid")
+	_, resp, err = Test(app, req)
+	utils.AssertEqual(t, ErrBadHandshake, err)
+	utils.AssertEqual(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	// Origin not allowed
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderOrigin, "https://evil.com")
+	_, resp, err = Test(app, req)
+	utils.AssertEqual(t, ErrBadHandshake, err)
+	utils.AssertEqual(t, fiber.StatusForbidden, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "Forbidden", string(body))
+
+	// Subprotocol in server preference order
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderOrigin, "https://gofiber.io")
+	req.Header.Set(fiber.HeaderSecWebSocketProtocol, "text, json")
+	conn, resp, err := Test(app, req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "json", resp.Header.Get(fiber.HeaderSecWebSocketProtocol))
+	utils.AssertEqual(t, "json", conn.Subprotocol())
+	utils.AssertEqual(t, nil, conn.Close())
+
+	// No supported subprotocol
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderSecWebSocketProtocol, "xml")
+	conn, _, err = Test(app, req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", conn.Subprotocol())
+	utils.AssertEqual(t, nil, conn.Close())
+}
+
+// go test -run Test_WebSocket_Origins
+func Test_WebSocket_Origins(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/same", New(echo))
+	app.Get("/any", New(echo, Config{
+		Origins: []string{"*"},
+	}))
+
+	tests := []struct {
+		path   string
+		origin string
+		err    error
+	}{
+		// Same-origin by default
+		{"/same", "", nil},
+		{"/same", "https://example.com", nil},
+		{"/same", "https://evil.com", ErrBadHandshake},
+		{"/same", "null", ErrBadHandshake},
+		{"/same", "https://example.com:443", nil},
+		{"http://example.com:8080/same", "http://example.com:8080", nil},
+		{"http://example.com:8080/same", "http://example.com", ErrBadHandshake},
+		{"http://example.com:8080/same", "http://example.com:9090", ErrBadHandshake},
+		{"http://example.com:80/same", "http://example.com", nil},
+		// Any origin as opt-in
+		{"/any", "https://evil.com", nil},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.origin != "" {
+			req.Header.Set(fiber.HeaderOrigin, tt.origin)
+		}
+		conn, _, err := Test(app, req)
+		utils.AssertEqual(t, tt.err, err, tt.origin)
+		if err == nil {
+			utils.AssertEqual(t, nil, conn.Close())
+		}
+	}
+}
+
+// go test -run Test_WebSocket_Control_Messages
+func Test_WebSocket_Control_Messages(t *testing.T) {
+	app := fiber.New()
+
+	closed := make(chan error, 1)
+	app.Get("/ws", New(func(c *Conn) {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+			_ = c.WriteMessage(TextMessage, []byte("ok"))
+		}
+	}))
+
+	conn, _, err := Test(app, httptest.NewRequest("GET", "/ws", nil))
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+
+	// The server answers a ping with a pong
+	pong := ""
+	conn.SetPongHandler(func(appData string) error {
+		pong = appData
+		return nil
+	})
+	utils.AssertEqual(t, nil, conn.WriteMessage(PingMessage, []byte("ping")))
+	utils.AssertEqual(t, nil, conn.WriteMessage(TextMessage, []byte("hello")))
+	_, msg, err := conn.ReadMessage()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "ok", string(msg))
+	utils.AssertEqual(t, "ping", pong)
+
+	// The server echoes the close message
+	utils.AssertEqual(t, nil, conn.WriteMessage(CloseMessage, FormatCloseMessage(CloseNormalClosure, "bye")))
+	utils.AssertEqual(t, ErrCloseSent, conn.WriteMessage(TextMessage, []byte("hello")))
+	_, _, err = conn.ReadMessage()
+	utils.AssertEqual(t, true, IsCloseError(err, CloseNormalClosure))
+	utils.AssertEqual(t, false, IsUnexpectedCloseError(err, CloseNormalClosure))
+
+	err = <-closed
+	utils.AssertEqual(t, &CloseError{Code: CloseNormalClosure, Text: "bye"}, err)
+	utils.AssertEqual(t, "websocket: close 1000: bye", err.Error())
+}
+
+// go test -run Test_WebSocket_Fragmented_Message
+func Test_WebSocket_Fragmented_Message(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/ws", New(echo))
+
+	conn, _, err := Test(app, httptest.NewRequest("GET", "/ws", nil))
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+
+	// "hello" split in two frames with a ping in between, masked with a zero key
+	_, err = conn.UnderlyingConn().Write([]byte{
+		TextMessage, maskBit | 3, 0, 0, 0, 0, 'h', 'e', 'l',
+		finalBit | PingMessage, maskBit, 0, 0, 0, 0,
+		finalBit | continuationFrame, maskBit | 2, 0, 0, 0, 0, 'l', 'o',
+	})
+	utils.AssertEqual(t, nil, err)
+
+	mt, msg, err := conn.ReadMessage()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, TextMessage, mt)
+	utils.AssertEqual(t, "hello", string(msg))
+}
+
+// go test -run Test_WebSocket_Errors
+func Test_WebSocket_Errors(t *testing.T) {
+	app := fiber.New()
+
+	errs := make(chan error, 1)
+	app.Get("/ws", New(func(c *Conn) {
+		c.SetReadLimit(4)
+		_, _, err := c.ReadMessage()
+		errs <- err
+	}))
+
+	tests := []struct {
+		frame []byte
+		err   error
+		code  int
+	}{
+		// Message larger than the read limit
+		{[]byte{finalBit | TextMessage, maskBit | 5, 0, 0, 0, 0, 'h', 'e', 'l', 'l', 'o'}, ErrReadLimit, CloseMessageTooBig},
+		// Unmasked client frame
+		{[]byte{finalBit | TextMessage, 2, 'h', 'i'}, protocolError("incorrect mask flag"), CloseProtocolError},
+		// Invalid UTF-8
+		{[]byte{finalBit | TextMessage, maskBit | 1, 0, 0, 0, 0, 0xff}, errInvalidUTF8, CloseInvalidFramePayloadData},
+		// Continuation without a message
+		{[]byte{finalBit | continuationFrame, maskBit, 0, 0, 0, 0}, protocolError("continuation frame without a message"), CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		conn, _, err := Test(app, httptest.NewRequest("GET", "/ws", nil))
+		utils.AssertEqual(t, nil, err)
+
+		_, err = conn.UnderlyingConn().Write(tt.frame)
+		utils.AssertEqual(t, nil, err)
+
+		_, _, err = conn.ReadMessage()
+		utils.AssertEqual(t, true, IsCloseError(err, tt.code))
+		utils.AssertEqual(t, tt.err, <-errs)
+		utils.AssertEqual(t, nil, conn.Close())
+	}
+}
+
+// go test -run Test_WebSocket_ReadLimit
+func Test_WebSocket_ReadLimit(t *testing.T) {
+	app := fiber.New()
+
+	errs := make(chan error, 1)
+	app.Get("/ws", New(func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errs <- err
+	}))
+
+	conn, _, err := Test(app, httptest.NewRequest("GET", "/ws", nil))
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+
+	// A frame header claiming the maximum payload length
+	_, err = conn.UnderlyingConn().Write([]byte{
+		finalBit | BinaryMessage, maskBit | 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0,
+	})
+	utils.AssertEqual(t, nil, err)
+
+	_, _, err = conn.ReadMessage()
+	utils.AssertEqual(t, true, IsCloseError(err, CloseMessageTooBig))
+	utils.AssertEqual(t, ErrReadLimit, <-errs)
+}
+
+// go test -run Test_WebSocket_Panic
+func Test_WebSocket_Panic(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/ws", New(func(c *Conn) {
+		panic("oops")
+	}))
+
+	conn, _, err := Test(app, httptest.NewRequest("GET", "/ws", nil))
+	utils.AssertEqual(t, nil, err)
+	defer conn.Close()
+
+	_, _, err = conn.ReadMessage()
+	utils.AssertEqual(t, true, IsCloseError(err, CloseInternalServerErr))
+}
+
+// go test -run Test_WebSocket_PanicHandler
+func Test_WebSocket_PanicHandler(t *testing.T) {
+	app := fiber.New()
+
+	var output bytes.Buffer
+	app.Get("/output", New(func(c *Conn) {
+		panic("oops")
+	}, Config{
+		Output: &output,
+	}))
+	reported := make(chan interface{}, 1)
+	app.Get("/handler", func(c *fiber.Ctx) error {
+		c.Locals("user", "john")
+		return c.Next()
+	}, New(func(c *Conn) {
+		panic("oops")
+	}, Config{
+		PanicHandler: func(conn *Conn, e interface{}) {
+			reported <- conn.Locals("user")
+		},
+	}))
+
+	conn, _, err := Test(app, httptest.NewRequest("GET", "/output", nil))
+	utils.AssertEqual(t, nil, err)
+	_, _, err = conn.ReadMessage()
+	utils.AssertEqual(t, true, IsCloseError(err, CloseInternalServerErr))
+	utils.AssertEqual(t, nil, conn.Close())
+	utils.AssertEqual(t, true, strings.HasPrefix(output.String(), "websocket: panic: oops\n"))
+
+	conn, _, err = Test(app, httptest.NewRequest("GET", "/handler", nil))
+	utils.AssertEqual(t, nil, err)
+	_, _, err = conn.ReadMessage()
+	utils.AssertEqual(t, true, IsCloseError(err, CloseInternalServerErr))
+	utils.AssertEqual(t, nil, conn.Close())
+	utils.AssertEqual(t, "john", <-reported)
+}
+
+// go test -run Test_WebSocket_Next
+func Test_WebSocket_Next(t *testing.T) {
+	app := fiber.New()
+
+	app.Get("/ws", New(echo, Config{
+		Next: func(_ *fiber.Ctx) bool {
+			return true
+		},
+	}))
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set(fiber.HeaderConnection, "Upgrade")
+	req.Header.Set(fiber.HeaderUpgrade, "websocket")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+}
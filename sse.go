@@ -0,0 +1,147 @@
+// ⚡️ Fiber is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/gofiber/fiber
+// 📌 API Documentation: https://docs.gofiber.io
+
+package fiber
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSSEHeartbeat is the interval between keep-alive comments on an event stream
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// ErrSSEClosed is returned when writing to an event stream whose client has gone away
+var ErrSSEClosed = errors.New("sse: stream is closed")
+
+// SSEEvent represents a single message of a Server-Sent Events stream.
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+type SSEEvent struct {
+	// ID sets the event ID, which the client reports back in the
+	// Last-Event-ID header when it reconnects.
+	ID string
+	// Event is the event type, the client defaults to "message" when empty.
+	Event string
+	// Data is the payload, multiple lines are sent as multiple data fields.
+	Data string
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// SSEWriter writes events to a Server-Sent Events stream opened by c.SSE.
+// It is safe to use from multiple goroutines.
+type SSEWriter struct {
+	mutex       sync.Mutex
+	w           *bufio.Writer
+	lastEventID string
+	done        chan struct{}
+	closed      bool
+}
+
+// LastEventID returns the Last-Event-ID header sent by a reconnecting client.
+func (sw *SSEWriter) LastEventID() string {
+	return sw.lastEventID
+}
+
+// Done returns a channel that is closed when the client has disconnected,
+// the request's UserContext is done or writing to the stream has failed.
+func (sw *SSEWriter) Done() <-chan struct{} {
+	return sw.done
+}
+
+// Send writes the event to the stream and flushes it to the client.
+func (sw *SSEWriter) Send(event SSEEvent) error {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	if sw.closed {
+		return ErrSSEClosed
+	}
+	if event.ID != "" {
+		sw.writeField("id", event.ID)
+	}
+	if event.Event != "" {
+		sw.writeField("event", event.Event)
+	}
+	if event.Retry > 0 {
+		sw.writeField("retry", strconv.FormatInt(int64(event.Retry/time.Millisecond), 10))
+	}
+	for _, line := range strings.Split(event.Data, "\n") {
+		sw.writeField("data", line)
+	}
+	_ = sw.w.WriteByte('\n')
+	return sw.flush()
+}
+
+// Comment writes a comment line, which clients ignore, and flushes it.
+func (sw *SSEWriter) Comment(text string) error {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	if sw.closed {
+		return ErrSSEClosed
+	}
+	for _, line := range strings.Split(text, "\n") {
+		_, _ = sw.w.WriteString(": " + removeNewLines(line) + "\n")
+	}
+	_ = sw.w.WriteByte('\n')
+	return sw.flush()
+}
+
+// writeField writes a single "name: value" line, stripping line breaks from the value
+func (sw *SSEWriter) writeField(name, value string) {
+	_, _ = sw.w.WriteString(name)
+	_, _ = sw.w.WriteString(": ")
+	_, _ = sw.w.WriteString(removeNewLines(value))
+	_ = sw.w.WriteByte('\n')
+}
+
+// flush sends the buffered data, a failing flush means the client is gone
+func (sw *SSEWriter) flush() error {
+	if err := sw.w.Flush(); err != nil {
+		sw.close()
+		return ErrSSEClosed
+	}
+	return nil
+}
+
+func (sw *SSEWriter) close() {
+	if !sw.closed {
+		sw.closed = true
+		close(sw.done)
+	}
+}
+
+// watch closes the stream when ctx is done, the default UserContext is
+// cancelled when the client disconnects even if nothing is written
+func (sw *SSEWriter) watch(ctx context.Context) {
+	select {
+	case <-sw.done:
+	case <-ctx.Done():
+		sw.mutex.Lock()
+		sw.close()
+		sw.mutex.Unlock()
+	}
+}
+
+// heartbeat keeps the connection alive until the stream is done
+func (sw *SSEWriter) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sw.done:
+			return
+		case <-ticker.C:
+			if err := sw.Comment("heartbeat"); err != nil {
+				return
+			}
+		}
+	}
+}
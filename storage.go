@@ -0,0 +1,33 @@
+// ⚡️ Fiber is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/gofiber/fiber
+// 📌 API Documentation: https://docs.gofiber.io
+
+package fiber
+
+import "time"
+
+// Storage interface for communicating with different database/key-value
+// providers. It is used by stateful middleware such as the limiter, csrf,
+// cache and session, so that state can be shared between processes, for
+// example when Prefork is enabled.
+type Storage interface {
+	// Get gets the value for the given key.
+	// It returns nil, nil when the key does not exist or has expired.
+	Get(key string) ([]byte, error)
+
+	// Set stores the given value for the given key along with a
+	// time-to-live expiration value, 0 means no expiration.
+	// Empty key or value will be ignored without an error.
+	Set(key string, val []byte, exp time.Duration) error
+
+	// Delete deletes the value for the given key.
+	// It returns no error if the storage does not contain the key.
+	Delete(key string) error
+
+	// Reset resets the storage and deletes all keys.
+	Reset() error
+
+	// Close closes the storage and will stop any running garbage
+	// collectors and open connections.
+	Close() error
+}
@@ -0,0 +1,105 @@
+// Package memory is an in-memory fiber.Storage, it is the default storage of
+// the middlewares and can be shared between them.
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// Storage is an in-memory fiber.Storage. Expired keys are removed by Set
+// every gcInterval, so no goroutine has to be stopped.
+type Storage struct {
+	mutex    sync.RWMutex
+	db       map[string]entry
+	interval int64
+	nextGC   int64
+}
+
+type entry struct {
+	data []byte
+	// unix time in nanoseconds, 0 means the entry never expires
+	expiry int64
+}
+
+// New creates a new memory storage, gcInterval defaults to 10 seconds
+func New(gcInterval ...time.Duration) *Storage {
+	interval := 10 * time.Second
+	if len(gcInterval) > 0 && gcInterval[0] > 0 {
+		interval = gcInterval[0]
+	}
+	return &Storage{
+		db:       make(map[string]entry),
+		interval: int64(interval),
+		nextGC:   time.Now().UnixNano() + int64(interval),
+	}
+}
+
+// Get value by key, the value is a copy
+func (s *Storage) Get(key string) ([]byte, error) {
+	if len(key) <= 0 {
+		return nil, nil
+	}
+	s.mutex.RLock()
+	e, ok := s.db[key]
+	s.mutex.RUnlock()
+	if !ok || (e.expiry != 0 && e.expiry <= time.Now().UnixNano()) {
+		return nil, nil
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+// Set key with value, the value is copied
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	var expiry int64
+	if exp > 0 {
+		expiry = now + int64(exp)
+	}
+	e := entry{data: append([]byte(nil), val...), expiry: expiry}
+	s.mutex.Lock()
+	if now >= s.nextGC {
+		s.gc(now)
+	}
+	s.db[key] = e
+	s.mutex.Unlock()
+	return nil
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	if len(key) <= 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	delete(s.db, key)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Reset all keys
+func (s *Storage) Reset() error {
+	s.mutex.Lock()
+	s.db = make(map[string]entry)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Close deletes all keys, the storage has no connections or goroutines
+func (s *Storage) Close() error {
+	return s.Reset()
+}
+
+// gc removes the expired keys, the caller holds the write lock
+func (s *Storage) gc(now int64) {
+	for key, e := range s.db {
+		if e.expiry != 0 && e.expiry <= now {
+			delete(s.db, key)
+		}
+	}
+	s.nextGC = now + s.interval
+}
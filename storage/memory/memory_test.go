@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var testStore = New()
+
+// go test -run Test_Memory_Set_Get
+func Test_Memory_Set_Get(t *testing.T) {
+	t.Parallel()
+	utils.AssertEqual(t, nil, testStore.Set("john", []byte("doe"), 0))
+
+	val, err := testStore.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), val)
+
+	val, err = testStore.Get("unknown")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+
+	// Empty keys and values are ignored
+	utils.AssertEqual(t, nil, testStore.Set("", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, testStore.Set("empty", nil, 0))
+	val, _ = testStore.Get("empty")
+	utils.AssertEqual(t, true, val == nil)
+}
+
+// go test -run Test_Memory_Expiration
+func Test_Memory_Expiration(t *testing.T) {
+	t.Parallel()
+	utils.AssertEqual(t, nil, testStore.Set("expired", []byte("doe"), 50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+
+	val, err := testStore.Get("expired")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, val == nil)
+}
+
+// go test -run Test_Memory_GC
+func Test_Memory_GC(t *testing.T) {
+	t.Parallel()
+	store := New(10 * time.Millisecond)
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 10*time.Millisecond))
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), 0))
+	time.Sleep(50 * time.Millisecond)
+
+	// Expired keys are removed by the next Set
+	utils.AssertEqual(t, nil, store.Set("joe", []byte("doe"), 0))
+	store.mutex.RLock()
+	utils.AssertEqual(t, 2, len(store.db))
+	store.mutex.RUnlock()
+}
+
+// go test -run Test_Memory_Get_Copy
+func Test_Memory_Get_Copy(t *testing.T) {
+	t.Parallel()
+	store := New()
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	val, err := store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	val[0] = 'D'
+
+	val, err = store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), val)
+}
+
+// go test -run Test_Memory_Delete_Reset
+func Test_Memory_Delete_Reset(t *testing.T) {
+	t.Parallel()
+	store := New()
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Delete("john"))
+	utils.AssertEqual(t, nil, store.Delete("unknown"))
+	val, _ := store.Get("john")
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Reset())
+	val, _ = store.Get("john")
+	utils.AssertEqual(t, true, val == nil)
+
+	utils.AssertEqual(t, nil, store.Close())
+	utils.AssertEqual(t, nil, store.Close())
+}
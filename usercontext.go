@@ -0,0 +1,73 @@
+// ⚡️ Fiber is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/gofiber/fiber
+// 📌 API Documentation: https://docs.gofiber.io
+
+package fiber
+
+import (
+	"context"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// userContextKey is the user value key of the userContextCloser of a request
+const userContextKey = "fiber.userContext"
+
+// newUserContext returns the default UserContext of a request, it is
+// cancelled when the client disconnects, the server shuts down or the
+// response has been written. The returned function cancels the context
+// right away, it is nil if the server cancels the context itself.
+func newUserContext(fctx *fasthttp.RequestCtx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := fctx.Conn()
+	if conn == nil {
+		// Not served by a server
+		return ctx, cancel
+	}
+
+	// Server shutdown
+	var wg sync.WaitGroup
+	done, stop := fctx.Done(), make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-done:
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	// Client disconnect
+	stopWatch := watchConn(conn, cancel)
+
+	// fasthttp closes the user values once the response has been written,
+	// so that streamed bodies can still use the context
+	var once sync.Once
+	fctx.SetUserValue(userContextKey, userContextCloser(func() {
+		once.Do(func() {
+			close(stop)
+			stopWatch()
+			wg.Wait()
+			cancel()
+		})
+	}))
+	return ctx, nil
+}
+
+// userContextCloser stops watching the request and cancels its context
+type userContextCloser func()
+
+func (fn userContextCloser) Close() error {
+	fn()
+	return nil
+}
+
+// cancelUserContext cancels the default UserContext of a hijacked
+// connection, fasthttp doesn't close the user values of those
+func cancelUserContext(fctx *fasthttp.RequestCtx) {
+	if closer, ok := fctx.UserValue(userContextKey).(userContextCloser); ok {
+		closer()
+	}
+}
@@ -0,0 +1,10 @@
+// ⚡️ Fiber is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/gofiber/fiber
+// 📌 API Documentation: https://docs.gofiber.io
+
+package fiber
+
+import "golang.org/x/sys/unix"
+
+// pollRDHUP is the poll event of the peer closing its side of a connection
+const pollRDHUP = unix.POLLRDHUP
@@ -0,0 +1,101 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// ⚡️ Fiber is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/gofiber/fiber
+// 📌 API Documentation: https://docs.gofiber.io
+
+package fiber
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchConn calls closed when the peer closes conn, it peeks at the socket
+// so the data of a streamed request body or a pipelined request stays
+// available to the server. The returned function stops watching and waits
+// until it is done, the deadlines of conn aren't touched.
+func watchConn(conn net.Conn, closed func()) (stop func()) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		// TLS connections can't be peeked without consuming records
+		return func() {}
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	// Writing to the pipe wakes up the poll
+	var wake [2]int
+	syscall.ForkLock.RLock()
+	err = unix.Pipe(wake[:])
+	if err == nil {
+		syscall.CloseOnExec(wake[0])
+		syscall.CloseOnExec(wake[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = rc.Control(func(fd uintptr) {
+			if pollConn(int(fd), wake[0]) {
+				closed()
+			}
+		})
+	}()
+
+	return func() {
+		_, _ = unix.Write(wake[1], []byte{0})
+		<-done
+		_ = unix.Close(wake[0])
+		_ = unix.Close(wake[1])
+	}
+}
+
+// pollConn waits until the peer closes the socket fd, then it returns true,
+// or until wake is readable, then it returns false
+func pollConn(fd, wake int) bool {
+	buf := make([]byte, 1)
+	events, timeout := int16(unix.POLLIN|pollRDHUP), -1
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(fd), Events: events},
+			{Fd: int32(wake), Events: unix.POLLIN},
+		}
+		n, err := unix.Poll(fds, timeout)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || fds[1].Revents != 0 || fds[0].Revents&unix.POLLNVAL != 0 {
+			return false
+		}
+		if fds[0].Revents&(unix.POLLHUP|unix.POLLERR|pollRDHUP) != 0 {
+			return true
+		}
+		if n > 0 && fds[0].Revents&unix.POLLIN == 0 {
+			continue
+		}
+
+		// Readable, or time to check again whether the data was read
+		n, _, err = unix.Recvfrom(fd, buf, unix.MSG_PEEK|unix.MSG_DONTWAIT)
+		switch {
+		case err == unix.EAGAIN || err == unix.EINTR:
+			// Nothing to read, wait until the socket is readable
+			events, timeout = unix.POLLIN|pollRDHUP, -1
+		case n == 0 || err != nil:
+			return true
+		default:
+			// The client sent more data, a readable socket says nothing
+			// about the peer until the server has read it
+			events, timeout = pollRDHUP, 100
+		}
+	}
+}
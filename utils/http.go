@@ -4,6 +4,11 @@
 
 package utils
 
+import (
+	"strconv"
+	"strings"
+)
+
 const MIMEOctetStream = "application/octet-stream"
 
 // GetMIME returns the content-type of a file extension
@@ -36,6 +41,49 @@ func StatusMessage(status int) string {
 	return statusMessage[status]
 }
 
+// NegotiateEncoding returns the encoding of encodings with the highest quality
+// in the Accept-Encoding header, or "" if none is acceptable. The order of
+// encodings breaks ties and "*" matches the encodings the header doesn't list.
+func NegotiateEncoding(header string, encodings ...string) string {
+	if header == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, quality := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				quality = parseQuality(param[2:])
+			}
+		}
+		qualities[ToLower(strings.TrimSpace(name))] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// parseQuality parses a qvalue, an invalid value counts as 0
+func parseQuality(s string) float64 {
+	q, err := strconv.ParseFloat(s, 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0
+	}
+	return q
+}
+
 // HTTP status codes were copied from net/http.
 var statusMessage = []string{
 	100: "Continue",
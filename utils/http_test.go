@@ -53,6 +53,22 @@ func Benchmark_GetMIME(b *testing.B) {
 	})
 }
 
+func Test_Utils_NegotiateEncoding(t *testing.T) {
+	t.Parallel()
+	encodings := []string{"br", "zstd", "gzip", "deflate"}
+	AssertEqual(t, "", NegotiateEncoding("", encodings...))
+	AssertEqual(t, "", NegotiateEncoding("identity", encodings...))
+	AssertEqual(t, "br", NegotiateEncoding("gzip, deflate, br", encodings...))
+	AssertEqual(t, "gzip", NegotiateEncoding("br;q=0.5, gzip", encodings...))
+	AssertEqual(t, "gzip", NegotiateEncoding("br;q=0, GZIP;q=0.8", encodings...))
+	AssertEqual(t, "br", NegotiateEncoding("*", encodings...))
+	AssertEqual(t, "deflate", NegotiateEncoding("*;q=0.1, deflate", encodings...))
+	AssertEqual(t, "", NegotiateEncoding("*;q=0", encodings...))
+	AssertEqual(t, "", NegotiateEncoding("gzip;q=2", encodings...))
+	AssertEqual(t, "zstd", NegotiateEncoding("zstd, gzip;q=0.5", encodings...))
+	AssertEqual(t, "gzip", NegotiateEncoding("zstd, gzip, br", "gzip", "br"))
+}
+
 func Test_Utils_StatusMessage(t *testing.T) {
 	t.Parallel()
 	res := StatusMessage(204)